@@ -53,6 +53,7 @@ func (p *Program) String() string {
 type ConstStatement struct {
 	Token token.Token
 	Name  *Identifier
+	Type  *Identifier // optional type annotation, e.g. const x: Int = 5;
 	Value Expression
 }
 
@@ -68,6 +69,9 @@ func (cs *ConstStatement) String() string {
 
 	out.WriteString(cs.TokenLiteral() + " ")
 	out.WriteString(cs.Name.String())
+	if cs.Type != nil {
+		out.WriteString(": " + cs.Type.String())
+	}
 	out.WriteString(" = ")
 
 	if cs.Value != nil {
@@ -79,6 +83,34 @@ func (cs *ConstStatement) String() string {
 	return out.String()
 }
 
+// ConstGroupStatement is a AST node representing const statement with multiple declarations, e.g. const a = 1, b = 2;
+type ConstGroupStatement struct {
+	Token        token.Token
+	Declarations []*ConstStatement
+}
+
+func (cgs *ConstGroupStatement) statementNode() {}
+
+// TokenLiteral returns the ConstGroupStatement's token.
+func (cgs *ConstGroupStatement) TokenLiteral() string {
+	return cgs.Token.Literal
+}
+
+func (cgs *ConstGroupStatement) String() string {
+	var out bytes.Buffer
+
+	declarations := []string{}
+	for _, d := range cgs.Declarations {
+		declarations = append(declarations, d.Name.String()+" = "+d.Value.String())
+	}
+
+	out.WriteString(cgs.TokenLiteral() + " ")
+	out.WriteString(strings.Join(declarations, ", "))
+	out.WriteString(";")
+
+	return out.String()
+}
+
 // ReturnStatement is a AST node representing "return" token.
 type ReturnStatement struct {
 	Token       token.Token
@@ -106,6 +138,23 @@ func (rs *ReturnStatement) String() string {
 	return out.String()
 }
 
+// YieldStatement is a AST node representing "yield" token, it suspends the generator function.
+type YieldStatement struct {
+	Token token.Token
+	Value Expression
+}
+
+func (ys *YieldStatement) statementNode() {}
+
+// TokenLiteral returns the YieldStatement's token.
+func (ys *YieldStatement) TokenLiteral() string {
+	return ys.Token.Literal
+}
+
+func (ys *YieldStatement) String() string {
+	return ys.TokenLiteral() + " " + ys.Value.String() + ";"
+}
+
 // ExpressionStatement is a AST node representing expression.
 // It is needed for expression to be part of program's Statements list.
 type ExpressionStatement struct {
@@ -185,6 +234,23 @@ func (il *IntegerLiteral) String() string {
 	return il.Token.Literal
 }
 
+// FloatLiteral is a AST node representing floating-point number token.
+type FloatLiteral struct {
+	Token token.Token
+	Value float64
+}
+
+func (fl *FloatLiteral) expressionNode() {}
+
+// TokenLiteral returns the FloatLiteral's token.
+func (fl *FloatLiteral) TokenLiteral() string {
+	return fl.Token.Literal
+}
+
+func (fl *FloatLiteral) String() string {
+	return fl.Token.Literal
+}
+
 // BooleanLiteral is a AST node representing boolean token.
 type BooleanLiteral struct {
 	Token token.Token
@@ -219,6 +285,34 @@ func (sl *StringLiteral) String() string {
 	return sl.Token.Literal
 }
 
+// TemplateLiteral is a node representing a string with embedded expressions, e.g. "${name} is ${age}".
+// Text between the expressions is represented by StringLiterals.
+type TemplateLiteral struct {
+	Token token.Token
+	Parts []Expression
+}
+
+func (tl *TemplateLiteral) expressionNode() {}
+
+// TokenLiteral returns the raw content of the string.
+func (tl *TemplateLiteral) TokenLiteral() string {
+	return tl.Token.Literal
+}
+
+func (tl *TemplateLiteral) String() string {
+	var out bytes.Buffer
+
+	for _, part := range tl.Parts {
+		if sl, ok := part.(*StringLiteral); ok {
+			out.WriteString(sl.Value)
+		} else {
+			out.WriteString("${" + part.String() + "}")
+		}
+	}
+
+	return out.String()
+}
+
 // PrefixExpression is a AST node representing  prefix expression, e.g. -1.
 type PrefixExpression struct {
 	Token    token.Token
@@ -271,6 +365,33 @@ func (ie *InfixExpression) String() string {
 	return out.String()
 }
 
+// RangeExpression is a AST node representing range of integers, e.g. 1..5 or 1..=5.
+type RangeExpression struct {
+	Token     token.Token // ".." or "..="
+	Start     Expression
+	End       Expression
+	Inclusive bool
+}
+
+func (re *RangeExpression) expressionNode() {}
+
+// TokenLiteral returns the RangeExpression's token.
+func (re *RangeExpression) TokenLiteral() string {
+	return re.Token.Literal
+}
+
+func (re *RangeExpression) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("(")
+	out.WriteString(re.Start.String())
+	out.WriteString(re.TokenLiteral())
+	out.WriteString(re.End.String())
+	out.WriteString(")")
+
+	return out.String()
+}
+
 // IfStatement is a AST node representing if statement // if (a < b) { print(a); } else { print(b); }
 type IfStatement struct {
 	Token       token.Token
@@ -301,11 +422,354 @@ func (is *IfStatement) String() string {
 	return out.String()
 }
 
+// IfExpression is a AST node representing if used as a value, e.g. [if (c) { 1 } else { 2 }]
+type IfExpression struct {
+	Token       token.Token
+	Condition   Expression
+	Consequence *BlockStatement
+	Alternative *BlockStatement
+}
+
+func (ie *IfExpression) expressionNode() {}
+
+// TokenLiteral returns the IfExpression's token.
+func (ie *IfExpression) TokenLiteral() string {
+	return ie.Token.Literal
+}
+
+func (ie *IfExpression) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("if")
+	out.WriteString(ie.Condition.String() + " ")
+	out.WriteString(ie.Consequence.String())
+
+	if ie.Alternative != nil {
+		out.WriteString("else ")
+		out.WriteString(ie.Alternative.String())
+	}
+
+	return out.String()
+}
+
+// ConditionalExpression is a AST node representing choice between two values, e.g. c ? 3 : 4
+type ConditionalExpression struct {
+	Token       token.Token
+	Condition   Expression
+	Consequence Expression
+	Alternative Expression
+}
+
+func (ce *ConditionalExpression) expressionNode() {}
+
+// TokenLiteral returns the ConditionalExpression's token.
+func (ce *ConditionalExpression) TokenLiteral() string {
+	return ce.Token.Literal
+}
+
+func (ce *ConditionalExpression) String() string {
+	return "(" + ce.Condition.String() + " ? " + ce.Consequence.String() + " : " + ce.Alternative.String() + ")"
+}
+
+// ForInStatement is a AST node representing loop over elements, e.g. for (x in [1, 2]) { print(x); }
+type ForInStatement struct {
+	Token    token.Token
+	Variable *Identifier
+	Iterable Expression
+	Body     *BlockStatement
+}
+
+func (fis *ForInStatement) statementNode() {}
+
+// TokenLiteral returns the ForInStatement's token.
+func (fis *ForInStatement) TokenLiteral() string {
+	return fis.Token.Literal
+}
+
+func (fis *ForInStatement) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("for(")
+	out.WriteString(fis.Variable.String())
+	out.WriteString(" in ")
+	out.WriteString(fis.Iterable.String())
+	out.WriteString(") ")
+	out.WriteString(fis.Body.String())
+
+	return out.String()
+}
+
+// ForStatement is a AST node representing loop with the variable bound to value of the update expression
+// in every following iteration, e.g. for (i = 0; i < 3; i + 1) { print(i); }
+type ForStatement struct {
+	Token     token.Token
+	Variable  *Identifier
+	Init      Expression
+	Condition Expression
+	Update    Expression
+	Body      *BlockStatement
+}
+
+func (fs *ForStatement) statementNode() {}
+
+// TokenLiteral returns the ForStatement's token.
+func (fs *ForStatement) TokenLiteral() string {
+	return fs.Token.Literal
+}
+
+func (fs *ForStatement) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("for(")
+	out.WriteString(fs.Variable.String() + " = " + fs.Init.String() + "; ")
+	out.WriteString(fs.Condition.String() + "; ")
+	out.WriteString(fs.Update.String())
+	out.WriteString(") ")
+	out.WriteString(fs.Body.String())
+
+	return out.String()
+}
+
+// WhileStatement is a AST node representing loop running as long as the condition is true, e.g. while (next(g) < 3) { print("x"); }
+type WhileStatement struct {
+	Token     token.Token
+	Condition Expression
+	Body      *BlockStatement
+}
+
+func (ws *WhileStatement) statementNode() {}
+
+// TokenLiteral returns the WhileStatement's token.
+func (ws *WhileStatement) TokenLiteral() string {
+	return ws.Token.Literal
+}
+
+func (ws *WhileStatement) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("while")
+	out.WriteString(ws.Condition.String() + " ")
+	out.WriteString(ws.Body.String())
+
+	return out.String()
+}
+
+// MatchExpression is a AST node representing choice of the first arm with a pattern matching the subject,
+// e.g. match p { Point(x, 0) => x; _ => 0 }
+type MatchExpression struct {
+	Token   token.Token
+	Subject Expression
+	Arms    []*MatchArm
+}
+
+// MatchArm is a single pattern of the MatchExpression with expression evaluated when the pattern matches.
+type MatchArm struct {
+	Pattern Expression
+	Body    Expression
+}
+
+func (me *MatchExpression) expressionNode() {}
+
+// TokenLiteral returns the MatchExpression's token.
+func (me *MatchExpression) TokenLiteral() string {
+	return me.Token.Literal
+}
+
+func (me *MatchExpression) String() string {
+	var out bytes.Buffer
+
+	arms := []string{}
+	for _, arm := range me.Arms {
+		arms = append(arms, arm.Pattern.String()+" => "+arm.Body.String())
+	}
+
+	out.WriteString("match ")
+	out.WriteString(me.Subject.String())
+	out.WriteString(" { ")
+	out.WriteString(strings.Join(arms, "; "))
+	out.WriteString(" }")
+
+	return out.String()
+}
+
+// TypeSwitchExpression is a AST node representing choice of the case naming type of the subject,
+// e.g. typeswitch (x) { case Int: x + 1; default: 0 }
+type TypeSwitchExpression struct {
+	Token   token.Token
+	Subject Expression
+	Cases   []*TypeCase
+	Default Expression
+}
+
+// TypeCase is a single case of the TypeSwitchExpression with expression evaluated when the subject is of the Type.
+type TypeCase struct {
+	Type *Identifier
+	Body Expression
+}
+
+func (tse *TypeSwitchExpression) expressionNode() {}
+
+// TokenLiteral returns the TypeSwitchExpression's token.
+func (tse *TypeSwitchExpression) TokenLiteral() string {
+	return tse.Token.Literal
+}
+
+func (tse *TypeSwitchExpression) String() string {
+	var out bytes.Buffer
+
+	cases := []string{}
+	for _, c := range tse.Cases {
+		cases = append(cases, "case "+c.Type.String()+": "+c.Body.String())
+	}
+	if tse.Default != nil {
+		cases = append(cases, "default: "+tse.Default.String())
+	}
+
+	out.WriteString("typeswitch (")
+	out.WriteString(tse.Subject.String())
+	out.WriteString(") { ")
+	out.WriteString(strings.Join(cases, "; "))
+	out.WriteString(" }")
+
+	return out.String()
+}
+
+// RecordPattern is a AST node representing pattern matching records of given type, e.g. Point(x, _)
+type RecordPattern struct {
+	Token  token.Token
+	Name   *Identifier
+	Fields []Expression
+}
+
+func (rp *RecordPattern) expressionNode() {}
+
+// TokenLiteral returns the RecordPattern's token.
+func (rp *RecordPattern) TokenLiteral() string {
+	return rp.Token.Literal
+}
+
+func (rp *RecordPattern) String() string {
+	fields := []string{}
+	for _, f := range rp.Fields {
+		fields = append(fields, f.String())
+	}
+
+	return rp.Name.String() + "(" + strings.Join(fields, ", ") + ")"
+}
+
+// ArrayPattern is a AST node representing pattern matching arrays, e.g. [head, ...tail]
+type ArrayPattern struct {
+	Token    token.Token // "["
+	Elements []Expression
+	Rest     *Identifier // nil if the pattern matches arrays of exact length
+}
+
+func (ap *ArrayPattern) expressionNode() {}
+
+// TokenLiteral returns the ArrayPattern's token.
+func (ap *ArrayPattern) TokenLiteral() string {
+	return ap.Token.Literal
+}
+
+func (ap *ArrayPattern) String() string {
+	elements := []string{}
+	for _, el := range ap.Elements {
+		elements = append(elements, el.String())
+	}
+	if ap.Rest != nil {
+		elements = append(elements, "..."+ap.Rest.String())
+	}
+
+	return "[" + strings.Join(elements, ", ") + "]"
+}
+
+// RecordStatement is a AST node representing declaration of a record type, e.g. record Point(x, y);
+type RecordStatement struct {
+	Token  token.Token
+	Name   *Identifier
+	Fields []*Identifier
+}
+
+func (rs *RecordStatement) statementNode() {}
+
+// TokenLiteral returns the RecordStatement's token.
+func (rs *RecordStatement) TokenLiteral() string {
+	return rs.Token.Literal
+}
+
+func (rs *RecordStatement) String() string {
+	var out bytes.Buffer
+
+	fields := []string{}
+	for _, f := range rs.Fields {
+		fields = append(fields, f.String())
+	}
+
+	out.WriteString(rs.TokenLiteral() + " ")
+	out.WriteString(rs.Name.String())
+	out.WriteString("(")
+	out.WriteString(strings.Join(fields, ", "))
+	out.WriteString(");")
+
+	return out.String()
+}
+
+// DoExpression is a AST node representing block evaluated in its own scope, e.g. do { const t = 2; t * 2 }
+type DoExpression struct {
+	Token token.Token
+	Body  *BlockStatement
+}
+
+func (de *DoExpression) expressionNode() {}
+
+// TokenLiteral returns the DoExpression's token.
+func (de *DoExpression) TokenLiteral() string {
+	return de.Token.Literal
+}
+
+func (de *DoExpression) String() string {
+	return "do " + de.Body.String()
+}
+
+// WithExpression is a AST node representing block evaluated with temporary bindings, e.g. with (x = 5) { x * 2 }
+type WithExpression struct {
+	Token  token.Token
+	Names  []*Identifier
+	Values []Expression
+	Body   *BlockStatement
+}
+
+func (we *WithExpression) expressionNode() {}
+
+// TokenLiteral returns the WithExpression's token.
+func (we *WithExpression) TokenLiteral() string {
+	return we.Token.Literal
+}
+
+func (we *WithExpression) String() string {
+	var out bytes.Buffer
+
+	bindings := []string{}
+	for i, name := range we.Names {
+		bindings = append(bindings, name.String()+" = "+we.Values[i].String())
+	}
+
+	out.WriteString("with(")
+	out.WriteString(strings.Join(bindings, ", "))
+	out.WriteString(") ")
+	out.WriteString(we.Body.String())
+
+	return out.String()
+}
+
 // FunctionLiteral is a AST node representing function literal.
+// Pure functions can be evaluated before running the program when called with literals.
 type FunctionLiteral struct {
 	Token      token.Token
 	Parameters []*Identifier
 	Body       *BlockStatement
+	Pure       bool
+	Generator  bool // true if the body contains yield statements
 }
 
 func (fl *FunctionLiteral) expressionNode() {}
@@ -324,6 +788,9 @@ func (fl *FunctionLiteral) String() string {
 		params = append(params, p.String())
 	}
 
+	if fl.Pure {
+		out.WriteString("pure ")
+	}
 	out.WriteString(fl.TokenLiteral())
 	out.WriteString("(")
 	out.WriteString(strings.Join(params, ", "))
@@ -363,6 +830,57 @@ func (ce *CallExpression) String() string {
 	return out.String()
 }
 
+// MethodCallExpression is a AST node representing call of a function on a receiver, e.g. arr.map(f).
+type MethodCallExpression struct {
+	Token     token.Token // "."
+	Receiver  Expression
+	Method    *Identifier
+	Arguments []Expression
+}
+
+func (mce *MethodCallExpression) expressionNode() {}
+
+// TokenLiteral returns the MethodCallExpression's token.
+func (mce *MethodCallExpression) TokenLiteral() string {
+	return mce.Token.Literal
+}
+
+func (mce *MethodCallExpression) String() string {
+	var out bytes.Buffer
+
+	args := []string{}
+	for _, a := range mce.Arguments {
+		args = append(args, a.String())
+	}
+
+	out.WriteString(mce.Receiver.String())
+	out.WriteString(".")
+	out.WriteString(mce.Method.String())
+	out.WriteString("(")
+	out.WriteString(strings.Join(args, ", "))
+	out.WriteString(")")
+
+	return out.String()
+}
+
+// PropertyExpression is a AST node representing access to a field of a record, e.g. point.x
+type PropertyExpression struct {
+	Token    token.Token // "."
+	Receiver Expression
+	Property *Identifier
+}
+
+func (pe *PropertyExpression) expressionNode() {}
+
+// TokenLiteral returns the PropertyExpression's token.
+func (pe *PropertyExpression) TokenLiteral() string {
+	return pe.Token.Literal
+}
+
+func (pe *PropertyExpression) String() string {
+	return pe.Receiver.String() + "." + pe.Property.String()
+}
+
 // ArrayLiteral is a expression represting an array.
 type ArrayLiteral struct {
 	token.Token // "["
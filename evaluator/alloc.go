@@ -0,0 +1,45 @@
+package evaluator
+
+import (
+	"sync/atomic"
+
+	"github.com/radlinskii/interpreter/object"
+)
+
+// number of values allocated by the evaluator, reported by the `objectCount` built-in function.
+// Only integers, floats, strings, arrays and hashes are counted, booleans and null are singletons.
+var allocations int64
+
+// ObjectCount returns how many values the evaluator has allocated since the program started.
+func ObjectCount() int64 {
+	return atomic.LoadInt64(&allocations)
+}
+
+func allocate() {
+	atomic.AddInt64(&allocations, 1)
+}
+
+func newInteger(value int64) *object.Integer {
+	allocate()
+	return &object.Integer{Value: value}
+}
+
+func newFloat(value float64) *object.Float {
+	allocate()
+	return &object.Float{Value: value}
+}
+
+func newString(value string) *object.String {
+	allocate()
+	return &object.String{Value: value}
+}
+
+func newArray(elements []object.Object) *object.Array {
+	allocate()
+	return &object.Array{Elements: elements}
+}
+
+func newHash(pairs map[object.HashKey]object.HashPair, keyType object.Type) *object.Hash {
+	allocate()
+	return &object.Hash{Pairs: pairs, KeyType: keyType}
+}
@@ -1,9 +1,27 @@
 package evaluator
 
 import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"math/bits"
+	"strconv"
+	"strings"
+
 	"github.com/radlinskii/interpreter/object"
 )
 
+// File system access used by the file built-ins, replaceable for sandboxing.
+var (
+	readFile  = ioutil.ReadFile
+	writeFile = func(path string, data []byte) error {
+		return ioutil.WriteFile(path, data, 0644)
+	}
+)
+
 var builtins = map[string]*object.Builtin{
 	"len": {
 		Fn: func(args ...object.Object) object.Object {
@@ -13,10 +31,12 @@ var builtins = map[string]*object.Builtin{
 
 			switch arg := args[0].(type) {
 			case *object.Array:
-				return &object.Integer{Value: int64(len(arg.Elements))}
+				return newInteger(int64(len(arg.Elements)))
 
 			case *object.String:
-				return &object.Integer{Value: int64(len(arg.Value))}
+				return newInteger(int64(len(arg.Value)))
+			case *object.Bytes:
+				return newInteger(int64(len(arg.Value)))
 			default:
 				return newError("argument to `len` not supported, got %s", args[0].Type())
 			}
@@ -72,7 +92,7 @@ var builtins = map[string]*object.Builtin{
 				newElements := make([]object.Object, length-1, length-1)
 				copy(newElements, arr.Elements[1:length])
 
-				return &object.Array{Elements: newElements}
+				return newArray(newElements)
 			}
 
 			return NULL
@@ -93,17 +113,1091 @@ var builtins = map[string]*object.Builtin{
 			copy(newElements, arr.Elements)
 			newElements[length] = args[1]
 
-			return &object.Array{Elements: newElements}
+			return newArray(newElements)
+		},
+	},
+	"flatten": {
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 1 && len(args) != 2 {
+				return newError("wrong number of arguments. got=%d want=1 or 2", len(args))
+			}
+
+			arr, ok := args[0].(*object.Array)
+			if !ok {
+				return newError("first argument to `flatten` not supported, got %s", args[0].Type())
+			}
+
+			depth := int64(1)
+			if len(args) == 2 {
+				d, ok := args[1].(*object.Integer)
+				if !ok {
+					return newError("second argument to `flatten` not supported, got %s", args[1].Type())
+				}
+				depth = d.Value
+			}
+
+			return newArray(flattenElements(arr.Elements, depth))
+		},
+	},
+	"chunk": {
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 2 {
+				return newError("wrong number of arguments. got=%d want=2", len(args))
+			}
+
+			arr, ok := args[0].(*object.Array)
+			if !ok {
+				return newError("first argument to `chunk` not supported, got %s", args[0].Type())
+			}
+			size, ok := args[1].(*object.Integer)
+			if !ok {
+				return newError("second argument to `chunk` not supported, got %s", args[1].Type())
+			}
+			if size.Value < 1 {
+				return newError("chunk size must be positive, got %d", size.Value)
+			}
+
+			chunks := []object.Object{}
+			for start := 0; start < len(arr.Elements); start += int(size.Value) {
+				end := start + int(size.Value)
+				if end > len(arr.Elements) {
+					end = len(arr.Elements)
+				}
+
+				elements := make([]object.Object, end-start)
+				copy(elements, arr.Elements[start:end])
+				chunks = append(chunks, newArray(elements))
+			}
+
+			return newArray(chunks)
+		},
+	},
+	"take": {
+		Fn: func(args ...object.Object) object.Object {
+			arr, count, err := arrayAndCount("take", args)
+			if err != nil {
+				return err
+			}
+
+			elements := make([]object.Object, count)
+			copy(elements, arr.Elements[:count])
+
+			return newArray(elements)
+		},
+	},
+	"drop": {
+		Fn: func(args ...object.Object) object.Object {
+			arr, count, err := arrayAndCount("drop", args)
+			if err != nil {
+				return err
+			}
+
+			elements := make([]object.Object, len(arr.Elements)-count)
+			copy(elements, arr.Elements[count:])
+
+			return newArray(elements)
+		},
+	},
+	"popcount": bitCountBuiltin("popcount", bits.OnesCount64),
+	"clz":      bitCountBuiltin("clz", bits.LeadingZeros64),
+	"ctz":      bitCountBuiltin("ctz", bits.TrailingZeros64),
+	"hex":      formatIntBuiltin("hex", "0x", 16),
+	"bin":      formatIntBuiltin("bin", "0b", 2),
+	"oct":      formatIntBuiltin("oct", "0o", 8),
+	"lazy": {
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d want=1", len(args))
+			}
+
+			if !isCallable(args[0]) {
+				return newError("argument to `lazy` not supported, got %s", args[0].Type())
+			}
+
+			return &object.Lazy{Function: args[0]}
+		},
+	},
+	"channel": {
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) > 1 {
+				return newError("wrong number of arguments. got=%d want=0 or 1", len(args))
+			}
+
+			capacity := int64(0)
+			if len(args) == 1 {
+				size, ok := args[0].(*object.Integer)
+				if !ok {
+					return newError("argument to `channel` not supported, got %s", args[0].Type())
+				}
+				if size.Value < 0 {
+					return newError("channel capacity must not be negative, got %d", size.Value)
+				}
+				capacity = size.Value
+			}
+
+			return &object.Channel{Capacity: int(capacity)}
+		},
+	},
+	"jsonStringify": {
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d want=1", len(args))
+			}
+
+			s, err := jsonStringify(args[0])
+			if err != nil {
+				return err
+			}
+
+			return newString(s)
+		},
+	},
+	"base64Encode": {
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d want=1", len(args))
+			}
+
+			switch arg := args[0].(type) {
+			case *object.String:
+				return newString(base64.StdEncoding.EncodeToString([]byte(arg.Value)))
+			case *object.Bytes:
+				return newString(base64.StdEncoding.EncodeToString(arg.Value))
+			default:
+				return newError("argument to `base64Encode` not supported, got %s", args[0].Type())
+			}
+		},
+	},
+	"base64Decode": {
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d want=1", len(args))
+			}
+
+			encoded, ok := args[0].(*object.String)
+			if !ok {
+				return newError("argument to `base64Decode` not supported, got %s", args[0].Type())
+			}
+
+			data, err := base64.StdEncoding.DecodeString(encoded.Value)
+			if err != nil {
+				return newError("invalid base64 input: %s", err)
+			}
+
+			return newString(string(data))
+		},
+	},
+	"base64DecodeBytes": {
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d want=1", len(args))
+			}
+
+			encoded, ok := args[0].(*object.String)
+			if !ok {
+				return newError("argument to `base64DecodeBytes` not supported, got %s", args[0].Type())
+			}
+
+			data, err := base64.StdEncoding.DecodeString(encoded.Value)
+			if err != nil {
+				return newError("invalid base64 input: %s", err)
+			}
+
+			return &object.Bytes{Value: data}
+		},
+	},
+	"type": {
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d want=1", len(args))
+			}
+
+			return newString(string(args[0].Type()))
+		},
+	},
+	"str": {
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d want=1", len(args))
+			}
+
+			return newString(args[0].Inspect())
+		},
+	},
+	"contains": {
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 2 {
+				return newError("wrong number of arguments. got=%d want=2", len(args))
+			}
+
+			arr, ok := args[0].(*object.Array)
+			if !ok {
+				return newError("first argument to `contains` not supported, got %s", args[0].Type())
+			}
+
+			return evalBoolToBooleanObjectReference(indexOf(arr, args[1]) >= 0)
+		},
+	},
+	"index": {
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 2 {
+				return newError("wrong number of arguments. got=%d want=2", len(args))
+			}
+
+			arr, ok := args[0].(*object.Array)
+			if !ok {
+				return newError("first argument to `index` not supported, got %s", args[0].Type())
+			}
+
+			// null rather than -1, which is a valid index of the last element
+			i := indexOf(arr, args[1])
+			if i < 0 {
+				return NULL
+			}
+
+			return newInteger(int64(i))
+		},
+	},
+	"split": {
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 2 {
+				return newError("wrong number of arguments. got=%d want=2", len(args))
+			}
+
+			str, ok := args[0].(*object.String)
+			if !ok {
+				return newError("first argument to `split` not supported, got %s", args[0].Type())
+			}
+			sep, ok := args[1].(*object.String)
+			if !ok {
+				return newError("second argument to `split` not supported, got %s", args[1].Type())
+			}
+
+			parts := strings.Split(str.Value, sep.Value)
+			elements := make([]object.Object, len(parts))
+			for i, part := range parts {
+				elements[i] = newString(part)
+			}
+
+			return newArray(elements)
+		},
+	},
+	"join": {
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 2 {
+				return newError("wrong number of arguments. got=%d want=2", len(args))
+			}
+
+			arr, ok := args[0].(*object.Array)
+			if !ok {
+				return newError("first argument to `join` not supported, got %s", args[0].Type())
+			}
+			sep, ok := args[1].(*object.String)
+			if !ok {
+				return newError("second argument to `join` not supported, got %s", args[1].Type())
+			}
+
+			parts := make([]string, len(arr.Elements))
+			for i, el := range arr.Elements {
+				str, ok := el.(*object.String)
+				if !ok {
+					return newError("elements of array passed to `join` must be STRING, got %s", el.Type())
+				}
+				parts[i] = str.Value
+			}
+
+			return newString(strings.Join(parts, sep.Value))
+		},
+	},
+	"objectCount": {
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 0 {
+				return newError("wrong number of arguments. got=%d want=0", len(args))
+			}
+
+			return &object.Integer{Value: ObjectCount()}
+		},
+	},
+	"int": {
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d want=1", len(args))
+			}
+
+			switch arg := args[0].(type) {
+			case *object.Integer:
+				return arg
+			case *object.Float:
+				// the float has to be in range before the conversion, as converting floats out of it is undefined
+				if math.IsNaN(arg.Value) || arg.Value >= math.MaxInt64 || arg.Value < math.MinInt64 {
+					return newError("float out of integer range: %s", arg.Inspect())
+				}
+
+				return newInteger(int64(arg.Value))
+			case *object.String:
+				value, err := strconv.ParseInt(arg.Value, 10, 64)
+				if err != nil {
+					return newError("could not parse %q as integer", arg.Value)
+				}
+
+				return newInteger(value)
+			default:
+				return newError("argument to `int` not supported, got %s", args[0].Type())
+			}
+		},
+	},
+	"hash": {
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d want=1", len(args))
+			}
+
+			d, err := digest(args[0])
+			if err != nil {
+				return err
+			}
+
+			return newInteger(int64(d))
+		},
+	},
+	"template": {
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 2 {
+				return newError("wrong number of arguments. got=%d want=2", len(args))
+			}
+
+			tmpl, ok := args[0].(*object.String)
+			if !ok {
+				return newError("first argument to `template` not supported, got %s", args[0].Type())
+			}
+
+			values, ok := args[1].(*object.Hash)
+			if !ok {
+				return newError("second argument to `template` not supported, got %s", args[1].Type())
+			}
+
+			return fillTemplate(tmpl.Value, values)
+		},
+	},
+	"envGet": {
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 2 {
+				return newError("wrong number of arguments. got=%d want=2", len(args))
+			}
+
+			env, ok := args[0].(*object.Env)
+			if !ok {
+				return newError("first argument to `envGet` not supported, got %s", args[0].Type())
+			}
+			name, ok := args[1].(*object.String)
+			if !ok {
+				return newError("second argument to `envGet` not supported, got %s", args[1].Type())
+			}
+
+			if val, ok := env.Value.Get(name.Value); ok {
+				return val
+			}
+
+			return newError("unknown identifier: %s", name.Value)
+		},
+	},
+	"envSet": {
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 3 {
+				return newError("wrong number of arguments. got=%d want=3", len(args))
+			}
+
+			env, ok := args[0].(*object.Env)
+			if !ok {
+				return newError("first argument to `envSet` not supported, got %s", args[0].Type())
+			}
+			name, ok := args[1].(*object.String)
+			if !ok {
+				return newError("second argument to `envSet` not supported, got %s", args[1].Type())
+			}
+
+			if _, ok := env.Value.ShallowGet(name.Value); ok {
+				return newError("redeclared constant: %q in one block", name.Value)
+			}
+
+			return env.Value.Set(name.Value, args[2])
+		},
+	},
+	"typedHash": {
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d want=1", len(args))
+			}
+
+			keyType, ok := args[0].(*object.String)
+			if !ok {
+				return newError("argument to `typedHash` not supported, got %s", args[0].Type())
+			}
+
+			switch object.Type(keyType.Value) {
+			case object.INTEGER, object.STRING, object.BOOLEAN:
+				return newHash(make(map[object.HashKey]object.HashPair), object.Type(keyType.Value))
+			default:
+				return newError("%s can't be used as hash key type", keyType.Value)
+			}
+		},
+	},
+	"put": {
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 3 {
+				return newError("wrong number of arguments. got=%d want=3", len(args))
+			}
+
+			hash, ok := args[0].(*object.Hash)
+			if !ok {
+				return newError("first argument to `put` not supported, got %s", args[0].Type())
+			}
+			key, ok := args[1].(object.Hashable)
+			if !ok {
+				return newError("%s can't be used as hash key", args[1].Type())
+			}
+			if !hash.AcceptsKey(args[1]) {
+				return newError("key of type %s not allowed in hash of %s keys", args[1].Type(), hash.KeyType)
+			}
+
+			pairs := make(map[object.HashKey]object.HashPair, len(hash.Pairs)+1)
+			for k, pair := range hash.Pairs {
+				pairs[k] = pair
+			}
+			pairs[key.HashKey()] = object.HashPair{Key: args[1], Value: args[2]}
+
+			return newHash(pairs, hash.KeyType)
+		},
+	},
+	"readFile": {
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d want=1", len(args))
+			}
+
+			path, ok := args[0].(*object.String)
+			if !ok {
+				return newError("argument to `readFile` not supported, got %s", args[0].Type())
+			}
+
+			data, err := readFile(path.Value)
+			if err != nil {
+				return newError("could not read file: %s", err)
+			}
+
+			return newString(string(data))
+		},
+	},
+	"readFileBytes": {
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d want=1", len(args))
+			}
+
+			path, ok := args[0].(*object.String)
+			if !ok {
+				return newError("argument to `readFileBytes` not supported, got %s", args[0].Type())
+			}
+
+			data, err := readFile(path.Value)
+			if err != nil {
+				return newError("could not read file: %s", err)
+			}
+
+			return &object.Bytes{Value: data}
 		},
 	},
-	"print": {
+	"bytes": {
 		Fn: func(args ...object.Object) object.Object {
-			for _, arg := range args {
-				programOutput.WriteString(arg.Inspect() + " ")
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d want=1", len(args))
+			}
+
+			switch arg := args[0].(type) {
+			case *object.String:
+				return &object.Bytes{Value: []byte(arg.Value)}
+			case *object.Array:
+				data := make([]byte, len(arg.Elements))
+				for i, el := range arg.Elements {
+					integer, ok := el.(*object.Integer)
+					if !ok {
+						return newError("elements of array passed to `bytes` must be INTEGER, got %s", el.Type())
+					}
+					if integer.Value < 0 || integer.Value > 255 {
+						return newError("byte value out of range: %d", integer.Value)
+					}
+					data[i] = byte(integer.Value)
+				}
+
+				return &object.Bytes{Value: data}
+			default:
+				return newError("argument to `bytes` not supported, got %s", args[0].Type())
+			}
+		},
+	},
+	"writeFile": {
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 2 {
+				return newError("wrong number of arguments. got=%d want=2", len(args))
+			}
+
+			path, ok := args[0].(*object.String)
+			if !ok {
+				return newError("first argument to `writeFile` not supported, got %s", args[0].Type())
+			}
+			content, ok := args[1].(*object.String)
+			if !ok {
+				return newError("second argument to `writeFile` not supported, got %s", args[1].Type())
+			}
+
+			if err := writeFile(path.Value, []byte(content.Value)); err != nil {
+				return newError("could not write file: %s", err)
 			}
-			programOutput.WriteString("\n")
 
 			return NULL
 		},
 	},
 }
+
+// Built-ins that need access to the environment they were called from.
+var envBuiltins = map[string]func(env *object.Environment) *object.Builtin{
+	"print": func(env *object.Environment) *object.Builtin {
+		return &object.Builtin{
+			Fn: func(args ...object.Object) object.Object {
+				out := env.Output()
+				for _, arg := range args {
+					fmt.Fprint(out, arg.Inspect()+" ")
+				}
+				fmt.Fprint(out, "\n")
+
+				return NULL
+			},
+		}
+	},
+	"puts": func(env *object.Environment) *object.Builtin {
+		return &object.Builtin{
+			Fn: func(args ...object.Object) object.Object {
+				out := env.Output()
+				for _, arg := range args {
+					fmt.Fprintln(out, arg.Inspect())
+				}
+
+				return NULL
+			},
+		}
+	},
+	"write": func(env *object.Environment) *object.Builtin {
+		return &object.Builtin{
+			Fn: func(args ...object.Object) object.Object {
+				out := env.Output()
+				for _, arg := range args {
+					fmt.Fprint(out, arg.Inspect())
+				}
+
+				return NULL
+			},
+		}
+	},
+	"readInt": readNumberBuiltin("readInt", func(line string) object.Object {
+		value, err := strconv.ParseInt(line, 10, 64)
+		if err != nil {
+			return newError("could not parse %q as integer", line)
+		}
+
+		return newInteger(value)
+	}),
+	"readFloat": readNumberBuiltin("readFloat", func(line string) object.Object {
+		value, err := strconv.ParseFloat(line, 64)
+		if err != nil {
+			return newError("could not parse %q as float", line)
+		}
+
+		return newFloat(value)
+	}),
+	"next": func(env *object.Environment) *object.Builtin {
+		return &object.Builtin{
+			Fn: func(args ...object.Object) object.Object {
+				if len(args) != 1 {
+					return newError("wrong number of arguments. got=%d want=1", len(args))
+				}
+
+				gen, ok := args[0].(*object.Generator)
+				if !ok {
+					return newError("argument to `next` not supported, got %s", args[0].Type())
+				}
+
+				return resumeGenerator(gen, env)
+			},
+		}
+	},
+	"wait": func(env *object.Environment) *object.Builtin {
+		return &object.Builtin{
+			Fn: func(args ...object.Object) object.Object {
+				if len(args) != 1 {
+					return newError("wrong number of arguments. got=%d want=1", len(args))
+				}
+
+				task, ok := args[0].(*object.Task)
+				if !ok {
+					return newError("argument to `wait` not supported, got %s", args[0].Type())
+				}
+
+				return waitTask(task, env)
+			},
+		}
+	},
+	"send": func(env *object.Environment) *object.Builtin {
+		return &object.Builtin{
+			Fn: func(args ...object.Object) object.Object {
+				if len(args) != 2 {
+					return newError("wrong number of arguments. got=%d want=2", len(args))
+				}
+
+				ch, ok := args[0].(*object.Channel)
+				if !ok {
+					return newError("first argument to `send` not supported, got %s", args[0].Type())
+				}
+
+				return sendValue(ch, args[1], env)
+			},
+		}
+	},
+	"recv": func(env *object.Environment) *object.Builtin {
+		return &object.Builtin{
+			Fn: func(args ...object.Object) object.Object {
+				if len(args) != 1 {
+					return newError("wrong number of arguments. got=%d want=1", len(args))
+				}
+
+				ch, ok := args[0].(*object.Channel)
+				if !ok {
+					return newError("argument to `recv` not supported, got %s", args[0].Type())
+				}
+
+				return receiveValue(ch, env)
+			},
+		}
+	},
+	"currentEnv": func(env *object.Environment) *object.Builtin {
+		return &object.Builtin{
+			Fn: func(args ...object.Object) object.Object {
+				if len(args) != 0 {
+					return newError("wrong number of arguments. got=%d want=0", len(args))
+				}
+
+				return &object.Env{Value: env}
+			},
+		}
+	},
+}
+
+// Higher-order built-ins call back into the evaluator from the environment they were called from,
+// so they are registered in init to avoid an initialization cycle.
+func init() {
+	envBuiltins["map"] = func(env *object.Environment) *object.Builtin {
+		return &object.Builtin{
+			Fn: func(args ...object.Object) object.Object {
+				if len(args) != 2 {
+					return newError("wrong number of arguments. got=%d want=2", len(args))
+				}
+
+				arr, ok := args[0].(*object.Array)
+				if !ok {
+					return newError("first argument to `map` not supported, got %s", args[0].Type())
+				}
+				if !isCallable(args[1]) {
+					return newError("second argument to `map` not supported, got %s", args[1].Type())
+				}
+
+				newElements := make([]object.Object, 0, len(arr.Elements))
+				for _, el := range arr.Elements {
+					result := applyFunction(args[1], []object.Object{el}, env)
+					if isError(result) {
+						return result
+					}
+					newElements = append(newElements, result)
+				}
+
+				return newArray(newElements)
+			},
+		}
+	}
+	envBuiltins["filter"] = func(env *object.Environment) *object.Builtin {
+		return &object.Builtin{
+			Fn: func(args ...object.Object) object.Object {
+				if len(args) != 2 {
+					return newError("wrong number of arguments. got=%d want=2", len(args))
+				}
+
+				arr, ok := args[0].(*object.Array)
+				if !ok {
+					return newError("first argument to `filter` not supported, got %s", args[0].Type())
+				}
+				if !isCallable(args[1]) {
+					return newError("second argument to `filter` not supported, got %s", args[1].Type())
+				}
+
+				newElements := []object.Object{}
+				for _, el := range arr.Elements {
+					result := applyFunction(args[1], []object.Object{el}, env)
+					if isError(result) {
+						return result
+					}
+
+					keep, ok := isTruthy(result)
+					if !ok {
+						return newError("expected BOOLEAN from `filter` callback, got: %s", result.Type())
+					}
+					if keep {
+						newElements = append(newElements, el)
+					}
+				}
+
+				return newArray(newElements)
+			},
+		}
+	}
+	envBuiltins["reduce"] = func(env *object.Environment) *object.Builtin {
+		return &object.Builtin{
+			Fn: func(args ...object.Object) object.Object {
+				if len(args) != 3 {
+					return newError("wrong number of arguments. got=%d want=3", len(args))
+				}
+
+				arr, ok := args[0].(*object.Array)
+				if !ok {
+					return newError("first argument to `reduce` not supported, got %s", args[0].Type())
+				}
+				if !isCallable(args[2]) {
+					return newError("third argument to `reduce` not supported, got %s", args[2].Type())
+				}
+
+				acc := args[1]
+				for _, el := range arr.Elements {
+					acc = applyFunction(args[2], []object.Object{acc, el}, env)
+					if isError(acc) {
+						return acc
+					}
+				}
+
+				return acc
+			},
+		}
+	}
+	envBuiltins["flatMap"] = func(env *object.Environment) *object.Builtin {
+		return &object.Builtin{
+			Fn: func(args ...object.Object) object.Object {
+				if len(args) != 2 {
+					return newError("wrong number of arguments. got=%d want=2", len(args))
+				}
+
+				arr, ok := args[0].(*object.Array)
+				if !ok {
+					return newError("first argument to `flatMap` not supported, got %s", args[0].Type())
+				}
+				if !isCallable(args[1]) {
+					return newError("second argument to `flatMap` not supported, got %s", args[1].Type())
+				}
+
+				newElements := []object.Object{}
+				for _, el := range arr.Elements {
+					result := applyFunction(args[1], []object.Object{el}, env)
+					if isError(result) {
+						return result
+					}
+
+					mapped, ok := result.(*object.Array)
+					if !ok {
+						return newError("expected ARRAY from `flatMap` callback, got: %s", result.Type())
+					}
+					newElements = append(newElements, mapped.Elements...)
+				}
+
+				return newArray(newElements)
+			},
+		}
+	}
+	envBuiltins["groupBy"] = func(env *object.Environment) *object.Builtin {
+		return &object.Builtin{
+			Fn: func(args ...object.Object) object.Object {
+				if len(args) != 2 {
+					return newError("wrong number of arguments. got=%d want=2", len(args))
+				}
+
+				arr, ok := args[0].(*object.Array)
+				if !ok {
+					return newError("first argument to `groupBy` not supported, got %s", args[0].Type())
+				}
+				if !isCallable(args[1]) {
+					return newError("second argument to `groupBy` not supported, got %s", args[1].Type())
+				}
+
+				pairs := make(map[object.HashKey]object.HashPair)
+				for _, el := range arr.Elements {
+					key := applyFunction(args[1], []object.Object{el}, env)
+					if isError(key) {
+						return key
+					}
+
+					hashKey, ok := key.(object.Hashable)
+					if !ok {
+						return newError("%s can't be used as hash key", key.Type())
+					}
+
+					hashed := hashKey.HashKey()
+					group, ok := pairs[hashed]
+					if !ok {
+						group = object.HashPair{Key: key, Value: newArray([]object.Object{})}
+					}
+					groupArr := group.Value.(*object.Array)
+					groupArr.Elements = append(groupArr.Elements, el)
+					pairs[hashed] = group
+				}
+
+				return newHash(pairs, "")
+			},
+		}
+	}
+	envBuiltins["times"] = func(env *object.Environment) *object.Builtin {
+		return &object.Builtin{
+			Fn: func(args ...object.Object) object.Object {
+				if len(args) != 2 {
+					return newError("wrong number of arguments. got=%d want=2", len(args))
+				}
+
+				count, ok := args[0].(*object.Integer)
+				if !ok {
+					return newError("first argument to `times` not supported, got %s", args[0].Type())
+				}
+				if count.Value < 0 {
+					return newError("count must not be negative, got %d", count.Value)
+				}
+				if !isCallable(args[1]) {
+					return newError("second argument to `times` not supported, got %s", args[1].Type())
+				}
+
+				for i := int64(0); i < count.Value; i++ {
+					result := applyFunction(args[1], []object.Object{newInteger(i)}, env)
+					if isError(result) {
+						return result
+					}
+				}
+
+				return NULL
+			},
+		}
+	}
+	envBuiltins["compose"] = func(env *object.Environment) *object.Builtin {
+		return &object.Builtin{
+			Fn: func(args ...object.Object) object.Object {
+				for _, arg := range args {
+					if !isCallable(arg) {
+						return newError("arguments to `compose` must be functions, got %s", arg.Type())
+					}
+				}
+				functions := append([]object.Object{}, args...)
+
+				// the last function gets all the arguments, the others get result of the following one
+				return &object.Builtin{Fn: func(args ...object.Object) object.Object {
+					for i := len(functions) - 1; i >= 0; i-- {
+						result := applyFunction(functions[i], args, env)
+						if isError(result) {
+							return result
+						}
+						args = []object.Object{result}
+					}
+
+					if len(args) != 1 {
+						return newError("wrong number of arguments. got=%d want=1", len(args))
+					}
+
+					return args[0]
+				}}
+			},
+		}
+	}
+	envBuiltins["spawn"] = func(env *object.Environment) *object.Builtin {
+		return &object.Builtin{
+			Fn: func(args ...object.Object) object.Object {
+				if len(args) != 1 {
+					return newError("wrong number of arguments. got=%d want=1", len(args))
+				}
+
+				if !isCallable(args[0]) {
+					return newError("argument to `spawn` not supported, got %s", args[0].Type())
+				}
+
+				return spawn(args[0], env)
+			},
+		}
+	}
+}
+
+// arrayAndCount validates arguments of the array slicing built-ins.
+// Returned count is clamped to the array's length.
+func arrayAndCount(name string, args []object.Object) (*object.Array, int, *object.Error) {
+	if len(args) != 2 {
+		return nil, 0, newError("wrong number of arguments. got=%d want=2", len(args))
+	}
+
+	arr, ok := args[0].(*object.Array)
+	if !ok {
+		return nil, 0, newError("first argument to `%s` not supported, got %s", name, args[0].Type())
+	}
+	count, ok := args[1].(*object.Integer)
+	if !ok {
+		return nil, 0, newError("second argument to `%s` not supported, got %s", name, args[1].Type())
+	}
+	if count.Value < 0 {
+		return nil, 0, newError("count must not be negative, got %d", count.Value)
+	}
+
+	if count.Value > int64(len(arr.Elements)) {
+		return arr, len(arr.Elements), nil
+	}
+
+	return arr, int(count.Value), nil
+}
+
+// fillTemplate replaces {name} placeholders with values of the hash's "name" keys.
+// "{{" and "}}" stand for literal braces.
+func fillTemplate(tmpl string, values *object.Hash) object.Object {
+	var out bytes.Buffer
+
+	for i := 0; i < len(tmpl); i++ {
+		ch := tmpl[i]
+
+		switch {
+		case (ch == '{' || ch == '}') && i+1 < len(tmpl) && tmpl[i+1] == ch:
+			out.WriteByte(ch)
+			i++
+		case ch == '{':
+			end := strings.IndexByte(tmpl[i:], '}')
+			if end == -1 {
+				return newError("placeholder not terminated in template: %q", tmpl)
+			}
+
+			name := tmpl[i+1 : i+end]
+			pair, ok := values.Pairs[(&object.String{Value: name}).HashKey()]
+			if !ok {
+				return newError("no value for placeholder %q in template", name)
+			}
+
+			out.WriteString(pair.Value.Inspect())
+			i += end
+		default:
+			out.WriteByte(ch)
+		}
+	}
+
+	return newString(out.String())
+}
+
+// indexOf returns index of the first element equal to the value, comparing arrays, hashes and records by their contents,
+// or -1 if there's no such element.
+func indexOf(arr *object.Array, value object.Object) int {
+	for i, el := range arr.Elements {
+		if valuesEqual(el, value) {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// readNumberBuiltin creates built-in reading a line from the environment's input and parsing it with given function,
+// surrounding whitespace is ignored. Without input the built-in returns null, just like at the end of the input.
+func readNumberBuiltin(name string, parse func(line string) object.Object) func(env *object.Environment) *object.Builtin {
+	return func(env *object.Environment) *object.Builtin {
+		return &object.Builtin{
+			Fn: func(args ...object.Object) object.Object {
+				if len(args) != 0 {
+					return newError("wrong number of arguments. got=%d want=0", len(args))
+				}
+
+				input := env.Input()
+				if input == nil {
+					return NULL
+				}
+
+				line, err := input.ReadString('\n')
+				if err == io.EOF && line == "" {
+					return NULL
+				} else if err != nil && err != io.EOF {
+					return newError("could not read input of `%s`: %s", name, err)
+				}
+
+				return parse(strings.TrimSpace(line))
+			},
+		}
+	}
+}
+
+// formatIntBuiltin creates built-in returning string representation of an integer in given base.
+// Negative integers are represented by the minus sign followed by the prefix and the absolute value, e.g. -0xff.
+func formatIntBuiltin(name, prefix string, base int) *object.Builtin {
+	return &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d want=1", len(args))
+			}
+
+			n, ok := args[0].(*object.Integer)
+			if !ok {
+				return newError("argument to `%s` not supported, got %s", name, args[0].Type())
+			}
+
+			digits := strconv.FormatInt(n.Value, base)
+			if strings.HasPrefix(digits, "-") {
+				return newString("-" + prefix + digits[1:])
+			}
+
+			return newString(prefix + digits)
+		},
+	}
+}
+
+// bitCountBuiltin creates built-in counting bits of an integer.
+// Integers are treated as 64-bit two's complement numbers, so e.g. popcount(-1) is 64.
+func bitCountBuiltin(name string, count func(uint64) int) *object.Builtin {
+	return &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d want=1", len(args))
+			}
+
+			n, ok := args[0].(*object.Integer)
+			if !ok {
+				return newError("argument to `%s` not supported, got %s", name, args[0].Type())
+			}
+
+			return newInteger(int64(count(uint64(n.Value))))
+		},
+	}
+}
+
+// flattenElements flattens nested arrays up to given depth, negative depth flattens them completely.
+func flattenElements(elements []object.Object, depth int64) []object.Object {
+	flat := []object.Object{}
+
+	for _, el := range elements {
+		nested, ok := el.(*object.Array)
+		if !ok || depth == 0 {
+			flat = append(flat, el)
+			continue
+		}
+
+		flat = append(flat, flattenElements(nested.Elements, depth-1)...)
+	}
+
+	return flat
+}
+
+func isCallable(obj object.Object) bool {
+	switch obj.(type) {
+	case *object.Function, *object.Builtin:
+		return true
+	default:
+		return false
+	}
+}
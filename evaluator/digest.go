@@ -0,0 +1,81 @@
+package evaluator
+
+import (
+	"encoding/binary"
+	"hash"
+	"hash/fnv"
+	"math"
+
+	"github.com/radlinskii/interpreter/object"
+)
+
+// digest returns a digest of the value which doesn't change between runs, equal values have equal digests.
+// Arrays, hashes and records are digested recursively, pairs of a hash are combined regardless of their order.
+// Integral floats are digested as integers, because they're equal, e.g. 1 == 1.0.
+func digest(obj object.Object) (uint64, *object.Error) {
+	if f, ok := obj.(*object.Float); ok && isIntegral(f.Value) {
+		obj = &object.Integer{Value: int64(f.Value)}
+	}
+
+	h := fnv.New64a()
+	h.Write([]byte(obj.Type()))
+
+	switch obj := obj.(type) {
+	case object.Hashable:
+		writeUint64(h, obj.HashKey().Value)
+	case *object.Float:
+		writeUint64(h, math.Float64bits(obj.Value))
+	case *object.Null:
+	case *object.Bytes:
+		h.Write(obj.Value)
+	case *object.Array:
+		for _, el := range obj.Elements {
+			if err := writeDigest(h, el); err != nil {
+				return 0, err
+			}
+		}
+	case *object.Hash:
+		var pairs uint64
+		for _, pair := range obj.Pairs {
+			pairDigest, err := digest(&object.Array{Elements: []object.Object{pair.Key, pair.Value}})
+			if err != nil {
+				return 0, err
+			}
+			pairs += pairDigest
+		}
+		writeUint64(h, pairs)
+	case *object.Record:
+		h.Write([]byte(obj.TypeName))
+		for _, name := range obj.FieldNames {
+			if err := writeDigest(h, obj.Fields[name]); err != nil {
+				return 0, err
+			}
+		}
+	default:
+		return 0, newError("value of type %s can't be hashed", obj.Type())
+	}
+
+	return h.Sum64(), nil
+}
+
+// checks if the float has no fraction and fits in an integer
+func isIntegral(f float64) bool {
+	return f == math.Trunc(f) && f >= math.MinInt64 && f < math.MaxInt64
+}
+
+func writeDigest(h hash.Hash64, obj object.Object) *object.Error {
+	d, err := digest(obj)
+	if err != nil {
+		return err
+	}
+
+	writeUint64(h, d)
+
+	return nil
+}
+
+func writeUint64(h hash.Hash64, value uint64) {
+	var buf [8]byte
+	binary.BigEndian.PutUint64(buf[:], value)
+	h.Write(buf[:])
+}
@@ -2,10 +2,15 @@ package evaluator
 
 import (
 	"bytes"
+	"context"
 	"fmt"
+	"math"
+	"strings"
+	"sync/atomic"
 
 	"github.com/radlinskii/interpreter/ast"
 	"github.com/radlinskii/interpreter/object"
+	"github.com/radlinskii/interpreter/token"
 )
 
 var (
@@ -19,8 +24,6 @@ var (
 	VOID = &object.Void{}
 )
 
-var programOutput bytes.Buffer
-
 // eval evaluates the AST
 func eval(node ast.Node, env *object.Environment) object.Object {
 	switch node := node.(type) {
@@ -33,37 +36,63 @@ func eval(node ast.Node, env *object.Environment) object.Object {
 		return evalIfStatement(node, env)
 	case *ast.ReturnStatement:
 		return evalReturnStatement(node, env)
+	case *ast.YieldStatement:
+		return evalYieldStatement(node, env)
 	case *ast.ConstStatement:
 		return evalConstStatement(node, env)
+	case *ast.ConstGroupStatement:
+		return evalConstGroupStatement(node, env)
+	case *ast.ForInStatement:
+		return evalForInStatement(node, env)
+	case *ast.ForStatement:
+		return evalForStatement(node, env)
+	case *ast.WhileStatement:
+		return evalWhileStatement(node, env)
+	case *ast.RecordStatement:
+		return evalRecordStatement(node, env)
 	//Expressions
 	case *ast.IntegerLiteral:
-		return &object.Integer{Value: node.Value}
+		return newInteger(node.Value)
+	case *ast.FloatLiteral:
+		return newFloat(node.Value)
 	case *ast.BooleanLiteral:
 		return evalBoolToBooleanObjectReference(node.Value)
 	case *ast.StringLiteral:
-		return &object.String{Value: node.Value}
+		return newString(node.Value)
+	case *ast.TemplateLiteral:
+		return evalTemplateLiteral(node, env)
 	case *ast.PrefixExpression:
-		right := eval(node.Right, env)
+		right := force(eval(node.Right, env), env)
 		if isError(right) {
 			return right
 		}
-		return evalPrefixExpression(node.Operator, right)
+		return atLine(evalPrefixExpression(node.Operator, right), node.Token)
 	case *ast.InfixExpression:
-		left := eval(node.Left, env)
+		if node.Operator == "&&" || node.Operator == "||" {
+			return evalLogicalExpression(node, env)
+		}
+
+		left := force(eval(node.Left, env), env)
 		if isError(left) {
 			return left
 		}
-		right := eval(node.Right, env)
+		right := force(eval(node.Right, env), env)
 		if isError(right) {
 			return right
 		}
-		return evalInfixExpression(node.Operator, left, right)
+		if env.BooleanArithmetic() && isArithmeticOperator(node.Operator) {
+			left, right = booleanToInteger(left), booleanToInteger(right)
+		}
+		if node.Operator == "/" && env.TrueDivision() && left.Type() == object.INTEGER && right.Type() == object.INTEGER {
+			return atLine(evalTrueDivision(left.(*object.Integer).Value, right.(*object.Integer).Value), node.Token)
+		}
+		return atLine(evalInfixExpression(node.Operator, left, right), node.Token)
 	case *ast.Identifier:
 		return evalIdentifier(node, env)
 	case *ast.FunctionLiteral:
 		params := node.Parameters
 		body := node.Body
-		return &object.Function{Parameters: params, Env: env, Body: body}
+		return &object.Function{Parameters: params, Env: env, Body: body, Generator: node.Generator}
 	case *ast.CallExpression:
 		fun := eval(node.Function, env)
 		if isError(fun) {
@@ -73,30 +102,59 @@ func eval(node ast.Node, env *object.Environment) object.Object {
 		if len(args) == 1 && isError(args[0]) {
 			return args[0]
 		}
-		return applyFunction(fun, args)
+		return atCallSite(applyFunction(fun, args, env), node.Token)
+	case *ast.MethodCallExpression:
+		return evalMethodCallExpression(node, env)
+	case *ast.PropertyExpression:
+		return evalPropertyExpression(node, env)
 	case *ast.ArrayLiteral:
 		elements := evalExpressions(node.Elements, env)
 		if len(elements) == 1 && isError(elements[0]) {
 			return elements[0]
 		}
-		return &object.Array{Elements: elements}
+		return newArray(elements)
 	case *ast.IndexExpression:
-		left := eval(node.Left, env)
+		left := force(eval(node.Left, env), env)
 		if isError(left) {
 			return left
 		}
-		right := eval(node.Right, env)
+		right := force(eval(node.Right, env), env)
 		if isError(right) {
 			return right
 		}
 		return evalIndexExpression(left, right)
 	case *ast.HashLiteral:
 		return evalHashLiteral(node, env)
+	case *ast.RangeExpression:
+		return evalRangeExpression(node, env)
+	case *ast.DoExpression:
+		return evalDoExpression(node, env)
+	case *ast.WithExpression:
+		return evalWithExpression(node, env)
+	case *ast.MatchExpression:
+		return evalMatchExpression(node, env)
+	case *ast.TypeSwitchExpression:
+		return evalTypeSwitchExpression(node, env)
+	case *ast.IfExpression:
+		return evalIf(node.Condition, node.Consequence, node.Alternative, "if-expression", env)
+	case *ast.ConditionalExpression:
+		return evalConditionalExpression(node, env)
 	default:
 		return nil
 	}
 }
 
+// Eval evaluates the AST and returns value of the last statement or the error which stopped the evaluation.
+// What the program prints is kept in the environment's Output.
+func Eval(program *ast.Program, env *object.Environment) object.Object {
+	evaluated := evalProgram(Fold(program, env), env)
+	if evaluated == nil {
+		return NULL
+	}
+
+	return evaluated
+}
+
 // EvalProgram starts evaluation of the AST.
 func evalProgram(program *ast.Program, env *object.Environment) object.Object {
 	var result object.Object
@@ -115,16 +173,11 @@ func evalProgram(program *ast.Program, env *object.Environment) object.Object {
 	return result
 }
 
-// EvalProgram starts evaluation of the AST.
+// EvalProgram starts evaluation of the AST and returns what the program printed followed by its result.
 func EvalProgram(program *ast.Program, env *object.Environment) string {
-	evaluated := evalProgram(program, env)
-
-	programOutput.WriteString(evaluated.Inspect())
+	evaluated := evalProgram(Fold(program, env), env)
 
-	retStr := programOutput.String()
-	programOutput.Reset()
-
-	return retStr
+	return env.Output().Drain() + evaluated.Inspect()
 }
 
 func evalBlockStatement(block *ast.BlockStatement, env *object.Environment) object.Object {
@@ -157,6 +210,32 @@ func evalPrefixExpression(operator string, right object.Object) object.Object {
 	}
 }
 
+// evalLogicalExpression evaluates && and || operators,
+// the right operand is evaluated only if the left one doesn't determine the result.
+func evalLogicalExpression(ie *ast.InfixExpression, env *object.Environment) object.Object {
+	left := force(eval(ie.Left, env), env)
+	if isError(left) {
+		return left
+	}
+	if left.Type() != object.BOOLEAN {
+		return newErrorAt(ie.Token, "expected BOOLEAN operands of %s, got: %s", ie.Operator, left.Type())
+	}
+
+	if (ie.Operator == "&&" && left == FALSE) || (ie.Operator == "||" && left == TRUE) {
+		return left
+	}
+
+	right := force(eval(ie.Right, env), env)
+	if isError(right) {
+		return right
+	}
+	if right.Type() != object.BOOLEAN {
+		return newErrorAt(ie.Token, "expected BOOLEAN operands of %s, got: %s", ie.Operator, right.Type())
+	}
+
+	return right
+}
+
 func evalBangOperatorExpression(right object.Object) object.Object {
 	switch right {
 	case TRUE:
@@ -169,22 +248,34 @@ func evalBangOperatorExpression(right object.Object) object.Object {
 }
 
 func evalMinusPrefixOperatorExpression(right object.Object) object.Object {
-	if right.Type() != object.INTEGER {
+	switch right := right.(type) {
+	case *object.Integer:
+		return newInteger(-right.Value)
+	case *object.Float:
+		return newFloat(-right.Value)
+	default:
 		return newError("unknown operator: -%s", right.Type())
 	}
-
-	value := right.(*object.Integer).Value
-	return &object.Integer{Value: -value}
 }
 
 func evalInfixExpression(operator string, left, right object.Object) object.Object {
 	switch {
+	case isNumber(left) && isNumber(right) && (left.Type() == object.FLOAT || right.Type() == object.FLOAT):
+		return evalFloatInfixExpression(operator, toFloat(left), toFloat(right))
 	case left.Type() != right.Type(): // handling type mismatch error first
 		return newError("type mismatch: %s %s %s", left.Type(), operator, right.Type())
 	case left.Type() == object.INTEGER:
 		return evalIntegerInfixExpression(operator, left, right)
 	case left.Type() == object.STRING:
 		return evalStringInfixExpression(operator, left, right)
+	case left.Type() == object.BOOLEAN:
+		return evalBooleanInfixExpression(operator, left, right)
+	case left.Type() == object.ARRAY && isOrderingOperator(operator):
+		return evalArrayOrderingExpression(operator, left, right)
+	case isComposite(left) && operator == "==":
+		return evalBoolToBooleanObjectReference(compositesEqual(left, right))
+	case isComposite(left) && operator == "!=":
+		return evalBoolToBooleanObjectReference(!compositesEqual(left, right))
 	case operator == "==":
 		return evalBoolToBooleanObjectReference(left == right)
 	case operator == "!=":
@@ -194,18 +285,125 @@ func evalInfixExpression(operator string, left, right object.Object) object.Obje
 	}
 }
 
+// evalFloatInfixExpression evaluates operation on floats, integer operands are promoted to floats before.
+func evalFloatInfixExpression(operator string, leftVal, rightVal float64) object.Object {
+	switch operator {
+	case "+":
+		return newFloat(leftVal + rightVal)
+	case "-":
+		return newFloat(leftVal - rightVal)
+	case "*":
+		return newFloat(leftVal * rightVal)
+	case "/":
+		return newFloat(leftVal / rightVal)
+	case "div":
+		if rightVal == 0 {
+			return newError("division by zero")
+		}
+		return newFloat(math.Floor(leftVal / rightVal))
+	case "%":
+		if rightVal == 0 {
+			return newError("division by zero")
+		}
+		return newFloat(math.Mod(leftVal, rightVal))
+	case "<":
+		return evalBoolToBooleanObjectReference(leftVal < rightVal)
+	case ">":
+		return evalBoolToBooleanObjectReference(leftVal > rightVal)
+	case "==":
+		return evalBoolToBooleanObjectReference(leftVal == rightVal)
+	case "!=":
+		return evalBoolToBooleanObjectReference(leftVal != rightVal)
+	case "<=":
+		return evalBoolToBooleanObjectReference(leftVal <= rightVal)
+	case ">=":
+		return evalBoolToBooleanObjectReference(leftVal >= rightVal)
+	default:
+		return newError("unknown operator: %s %s %s", object.FLOAT, operator, object.FLOAT)
+	}
+}
+
+func isNumber(obj object.Object) bool {
+	return obj.Type() == object.INTEGER || obj.Type() == object.FLOAT
+}
+
+// toFloat converts a number object to float64.
+func toFloat(obj object.Object) float64 {
+	if i, ok := obj.(*object.Integer); ok {
+		return float64(i.Value)
+	}
+
+	return obj.(*object.Float).Value
+}
+
+// floorDiv divides integers rounding the result towards negative infinity, e.g. -7 div 2 is -4.
+func floorDiv(leftVal, rightVal int64) int64 {
+	quotient := leftVal / rightVal
+	if leftVal%rightVal != 0 && (leftVal < 0) != (rightVal < 0) {
+		quotient--
+	}
+
+	return quotient
+}
+
+func isArithmeticOperator(operator string) bool {
+	switch operator {
+	case "+", "-", "*", "/", "div", "%":
+		return true
+	default:
+		return false
+	}
+}
+
+// booleanToInteger converts true to 1 and false to 0, other values are returned unchanged.
+func booleanToInteger(obj object.Object) object.Object {
+	switch obj {
+	case TRUE:
+		return newInteger(1)
+	case FALSE:
+		return newInteger(0)
+	default:
+		return obj
+	}
+}
+
+// evalTrueDivision divides integers resulting in a float if they aren't evenly divisible.
+func evalTrueDivision(leftVal, rightVal int64) object.Object {
+	if rightVal == 0 {
+		return newError("division by zero")
+	}
+	if leftVal%rightVal == 0 {
+		return newInteger(leftVal / rightVal)
+	}
+
+	return newFloat(float64(leftVal) / float64(rightVal))
+}
+
 func evalIntegerInfixExpression(operator string, left, right object.Object) object.Object {
 	leftVal := left.(*object.Integer).Value
 	rightVal := right.(*object.Integer).Value
 	switch operator {
 	case "+":
-		return &object.Integer{Value: leftVal + rightVal}
+		return newInteger(leftVal + rightVal)
 	case "-":
-		return &object.Integer{Value: leftVal - rightVal}
+		return newInteger(leftVal - rightVal)
 	case "*":
-		return &object.Integer{Value: leftVal * rightVal}
+		return newInteger(leftVal * rightVal)
 	case "/":
-		return &object.Integer{Value: leftVal / rightVal}
+		if rightVal == 0 {
+			return newError("division by zero")
+		}
+		return newInteger(leftVal / rightVal)
+	case "div":
+		if rightVal == 0 {
+			return newError("division by zero")
+		}
+		return newInteger(floorDiv(leftVal, rightVal))
+	case "%":
+		if rightVal == 0 {
+			return newError("division by zero")
+		}
+		return newInteger(leftVal % rightVal)
 	case "<":
 		return evalBoolToBooleanObjectReference(leftVal < rightVal)
 	case ">":
@@ -228,7 +426,21 @@ func evalStringInfixExpression(operator string, left, right object.Object) objec
 	rightVal := right.(*object.String).Value
 	switch operator {
 	case "+":
-		return &object.String{Value: leftVal + rightVal}
+		return newString(leftVal + rightVal)
+	case "==":
+		return evalBoolToBooleanObjectReference(leftVal == rightVal)
+	case "!=":
+		return evalBoolToBooleanObjectReference(leftVal != rightVal)
+	default:
+		return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
+	}
+}
+
+// evalBooleanInfixExpression only supports equality of booleans, they can't be ordered nor added.
+func evalBooleanInfixExpression(operator string, left, right object.Object) object.Object {
+	leftVal := left.(*object.Boolean).Value
+	rightVal := right.(*object.Boolean).Value
+	switch operator {
 	case "==":
 		return evalBoolToBooleanObjectReference(leftVal == rightVal)
 	case "!=":
@@ -238,6 +450,81 @@ func evalStringInfixExpression(operator string, left, right object.Object) objec
 	}
 }
 
+func isOrderingOperator(operator string) bool {
+	return operator == "<" || operator == ">" || operator == "<=" || operator == ">="
+}
+
+// evalArrayOrderingExpression compares arrays lexicographically.
+func evalArrayOrderingExpression(operator string, left, right object.Object) object.Object {
+	cmp, err := compareObjects(left, right)
+	if err != nil {
+		return err
+	}
+
+	switch operator {
+	case "<":
+		return evalBoolToBooleanObjectReference(cmp < 0)
+	case ">":
+		return evalBoolToBooleanObjectReference(cmp > 0)
+	case "<=":
+		return evalBoolToBooleanObjectReference(cmp <= 0)
+	default:
+		return evalBoolToBooleanObjectReference(cmp >= 0)
+	}
+}
+
+// compareObjects returns negative number if left is lower than right, positive if it's greater and 0 if they are equal.
+// Arrays are compared element by element, an array that is a prefix of the other one is lower.
+func compareObjects(left, right object.Object) (int, *object.Error) {
+	if left.Type() != right.Type() {
+		return 0, newError("incomparable elements: %s and %s", left.Type(), right.Type())
+	}
+
+	switch left := left.(type) {
+	case *object.Integer:
+		rightVal := right.(*object.Integer).Value
+		switch {
+		case left.Value < rightVal:
+			return -1, nil
+		case left.Value > rightVal:
+			return 1, nil
+		default:
+			return 0, nil
+		}
+	case *object.Float:
+		rightVal := right.(*object.Float).Value
+		switch {
+		case left.Value < rightVal:
+			return -1, nil
+		case left.Value > rightVal:
+			return 1, nil
+		default:
+			return 0, nil
+		}
+	case *object.String:
+		return strings.Compare(left.Value, right.(*object.String).Value), nil
+	case *object.Array:
+		rightElements := right.(*object.Array).Elements
+		for i, el := range left.Elements {
+			if i >= len(rightElements) {
+				return 1, nil
+			}
+
+			cmp, err := compareObjects(el, rightElements[i])
+			if err != nil || cmp != 0 {
+				return cmp, err
+			}
+		}
+
+		if len(left.Elements) < len(rightElements) {
+			return -1, nil
+		}
+		return 0, nil
+	default:
+		return 0, newError("incomparable elements: %s and %s", left.Type(), right.Type())
+	}
+}
+
 func evalBoolToBooleanObjectReference(val bool) object.Object {
 	if val {
 		return TRUE
@@ -246,25 +533,423 @@ func evalBoolToBooleanObjectReference(val bool) object.Object {
 }
 
 func evalIfStatement(ie *ast.IfStatement, env *object.Environment) object.Object {
-	condition := eval(ie.Condition, env)
+	return evalIf(ie.Condition, ie.Consequence, ie.Alternative, "if-statement", env)
+}
+
+// evalIf evaluates the block chosen by the condition, construct names the if in error messages.
+func evalIf(cond ast.Expression, consequence, alternative *ast.BlockStatement, construct string, env *object.Environment) object.Object {
+	condition := force(eval(cond, env), env)
+	if isError(condition) {
+		return condition
+	}
+
+	isConditionTrue, ok := isTruthy(condition)
+	if !ok {
+		return newError("expected BOOLEAN as condition in %s got: %s", construct, condition.Type())
+	}
+
+	if isConditionTrue {
+		return eval(consequence, env)
+	} else if alternative != nil {
+		return eval(alternative, env)
+	}
+
+	return NULL
+}
+
+// evalConditionalExpression evaluates only the operand chosen by the condition.
+func evalConditionalExpression(ce *ast.ConditionalExpression, env *object.Environment) object.Object {
+	condition := force(eval(ce.Condition, env), env)
 	if isError(condition) {
 		return condition
 	}
 
 	isConditionTrue, ok := isTruthy(condition)
 	if !ok {
-		return newError("expected BOOLEAN as condition in if-statement got: %s", condition.Type())
+		return newError("expected BOOLEAN as condition in conditional expression got: %s", condition.Type())
 	}
 
 	if isConditionTrue {
-		return eval(ie.Consequence, env)
-	} else if ie.Alternative != nil {
-		return eval(ie.Alternative, env)
+		return eval(ce.Consequence, env)
+	}
+
+	return eval(ce.Alternative, env)
+}
+
+// evalForInStatement evaluates loop's body for every element of an array.
+// Ranges are iterated without creating the array.
+func evalForInStatement(fis *ast.ForInStatement, env *object.Environment) object.Object {
+	if re, ok := fis.Iterable.(*ast.RangeExpression); ok {
+		return evalForInRange(fis, re, env)
+	}
+
+	iterable := force(eval(fis.Iterable, env), env)
+	if isError(iterable) {
+		return iterable
+	}
+
+	arr, ok := iterable.(*object.Array)
+	if !ok {
+		return newError("expected ARRAY in for-in loop, got: %s", iterable.Type())
+	}
+
+	for _, el := range arr.Elements {
+		result := evalLoopBody(fis, el, env)
+		if result != nil {
+			return result
+		}
+	}
+
+	return NULL
+}
+
+func evalForInRange(fis *ast.ForInStatement, re *ast.RangeExpression, env *object.Environment) object.Object {
+	start, end, err := evalRangeBounds(re, env)
+	if err != nil {
+		return err
+	}
+
+	for i := start; inRange(re, i, end); i++ {
+		result := evalLoopBody(fis, newInteger(i), env)
+		if result != nil {
+			return result
+		}
+
+		if i == end {
+			break
+		}
 	}
 
 	return NULL
 }
 
+// evalLoopBody evaluates loop's body with the loop variable bound to given value.
+// It returns nil if the loop should continue.
+func evalLoopBody(fis *ast.ForInStatement, value object.Object, env *object.Environment) object.Object {
+	if err := spendIteration(env); err != nil {
+		return err
+	}
+
+	loopEnv := object.NewEnclosedEnvironment(env)
+	loopEnv.Set(fis.Variable.Value, value)
+
+	result := eval(fis.Body, loopEnv)
+	if result != nil {
+		rt := result.Type()
+		if rt == object.RETURN || rt == object.ERROR {
+			return result
+		}
+	}
+
+	return nil
+}
+
+// evalForStatement evaluates loop's body as long as the condition is true.
+// Every iteration has a new scope with the variable bound to value of the update expression from the previous one.
+func evalForStatement(fs *ast.ForStatement, env *object.Environment) object.Object {
+	value := eval(fs.Init, env)
+	if isError(value) {
+		return value
+	}
+
+	for {
+		loopEnv := object.NewEnclosedEnvironment(env)
+		loopEnv.Set(fs.Variable.Value, value)
+
+		condition := force(eval(fs.Condition, loopEnv), loopEnv)
+		if isError(condition) {
+			return condition
+		}
+
+		isConditionTrue, ok := isTruthy(condition)
+		if !ok {
+			return newError("expected BOOLEAN as condition in for loop got: %s", condition.Type())
+		}
+		if !isConditionTrue {
+			return NULL
+		}
+		if err := spendIteration(env); err != nil {
+			return err
+		}
+
+		result := eval(fs.Body, loopEnv)
+		if result != nil {
+			rt := result.Type()
+			if rt == object.RETURN || rt == object.ERROR {
+				return result
+			}
+		}
+
+		value = eval(fs.Update, loopEnv)
+		if isError(value) {
+			return value
+		}
+	}
+}
+
+// evalWhileStatement evaluates loop's body in a new scope as long as the condition is true.
+func evalWhileStatement(ws *ast.WhileStatement, env *object.Environment) object.Object {
+	for {
+		condition := force(eval(ws.Condition, env), env)
+		if isError(condition) {
+			return condition
+		}
+
+		isConditionTrue, ok := isTruthy(condition)
+		if !ok {
+			return newError("expected BOOLEAN as condition in while loop got: %s", condition.Type())
+		}
+		if !isConditionTrue {
+			return NULL
+		}
+		if err := spendIteration(env); err != nil {
+			return err
+		}
+
+		result := eval(ws.Body, object.NewEnclosedEnvironment(env))
+		if result != nil {
+			rt := result.Type()
+			if rt == object.RETURN || rt == object.ERROR {
+				return result
+			}
+		}
+	}
+}
+
+// evalDoExpression evaluates the block in a new scope, it returns value of the last statement in the block.
+func evalDoExpression(de *ast.DoExpression, env *object.Environment) object.Object {
+	result := eval(de.Body, env)
+	if result == nil {
+		return NULL
+	}
+
+	return result
+}
+
+// spendIteration counts an iteration of a loop against the iteration budget, if the environment has one.
+// Loops are stopped as well once the evaluation gets cancelled.
+func spendIteration(env *object.Environment) *object.Error {
+	if err := checkCancelled(env); err != nil {
+		return err
+	}
+
+	budget := env.IterationBudget()
+	if budget == nil {
+		return nil
+	}
+
+	if atomic.AddInt64(&budget.Used, 1) > budget.Max {
+		return newError("iteration budget exceeded")
+	}
+
+	return nil
+}
+
+// checkCancelled returns an error if context of the environment is done.
+func checkCancelled(env *object.Environment) *object.Error {
+	ctx := env.Context()
+	if ctx != nil && ctx.Err() != nil {
+		return newError("evaluation cancelled")
+	}
+
+	return nil
+}
+
+// cancellation returns a channel closed once the context of the environment is done,
+// or nil, which blocks forever, if evaluation in the environment can't be cancelled.
+func cancellation(env *object.Environment) <-chan struct{} {
+	ctx := env.Context()
+	if ctx == nil {
+		return nil
+	}
+
+	return ctx.Done()
+}
+
+// evalWithExpression evaluates the block in a new scope with the bindings,
+// it returns value of the last statement in the block.
+func evalWithExpression(we *ast.WithExpression, env *object.Environment) object.Object {
+	withEnv := object.NewEnclosedEnvironment(env)
+
+	for i, name := range we.Names {
+		if _, ok := withEnv.ShallowGet(name.Value); ok {
+			return newError("redeclared constant: %q in one block", name.Value)
+		}
+
+		val := eval(we.Values[i], withEnv)
+		if isError(val) {
+			return val
+		}
+		withEnv.Set(name.Value, val)
+	}
+
+	result := eval(we.Body, withEnv)
+	if result == nil {
+		return NULL
+	}
+
+	return result
+}
+
+// evalMatchExpression evaluates body of the first arm with pattern matching the subject,
+// in a new scope with the pattern's bindings.
+func evalMatchExpression(me *ast.MatchExpression, env *object.Environment) object.Object {
+	subject := force(eval(me.Subject, env), env)
+	if isError(subject) {
+		return subject
+	}
+
+	for _, arm := range me.Arms {
+		armEnv := object.NewEnclosedEnvironment(env)
+
+		if matchPattern(arm.Pattern, subject, armEnv) {
+			return eval(arm.Body, armEnv)
+		}
+	}
+
+	return newError("no pattern matched: %s", subject.Inspect())
+}
+
+// types that can be named by cases of typeswitch
+var caseTypes = map[string]object.Type{
+	"Int":       object.INTEGER,
+	"Float":     object.FLOAT,
+	"String":    object.STRING,
+	"Bool":      object.BOOLEAN,
+	"Bytes":     object.BYTES,
+	"Null":      object.NULL,
+	"Void":      object.VOID,
+	"Function":  object.FUNCTION,
+	"Builtin":   object.BUILTIN,
+	"Array":     object.ARRAY,
+	"Hash":      object.HASH,
+	"Env":       object.ENV,
+	"Record":    object.RECORD,
+	"Lazy":      object.LAZY,
+	"Generator": object.GENERATOR,
+	"Task":      object.TASK,
+	"Channel":   object.CHANNEL,
+}
+
+// evalTypeSwitchExpression evaluates body of the first case naming type of the subject, or the default one.
+// All the case types are checked before, so misspelled ones are reported even if they are never reached.
+func evalTypeSwitchExpression(tse *ast.TypeSwitchExpression, env *object.Environment) object.Object {
+	for _, c := range tse.Cases {
+		if _, ok := caseTypes[c.Type.Value]; !ok {
+			return newErrorAt(c.Type.Token, "unknown type: %q", c.Type.Value)
+		}
+	}
+
+	subject := force(eval(tse.Subject, env), env)
+	if isError(subject) {
+		return subject
+	}
+
+	for _, c := range tse.Cases {
+		if subject.Type() == caseTypes[c.Type.Value] {
+			return eval(c.Body, env)
+		}
+	}
+
+	if tse.Default != nil {
+		return eval(tse.Default, env)
+	}
+
+	return newErrorAt(tse.Token, "no case matched type: %s", subject.Type())
+}
+
+// matchPattern checks if the value matches the pattern, binding the pattern's identifiers in given environment.
+func matchPattern(pattern ast.Expression, value object.Object, env *object.Environment) bool {
+	switch pattern := pattern.(type) {
+	case *ast.Identifier:
+		if pattern.Value != "_" {
+			env.Set(pattern.Value, value)
+		}
+
+		return true
+	case *ast.RecordPattern:
+		record, ok := value.(*object.Record)
+		if !ok || record.TypeName != pattern.Name.Value || len(record.FieldNames) != len(pattern.Fields) {
+			return false
+		}
+
+		for i, field := range pattern.Fields {
+			if !matchPattern(field, record.Fields[record.FieldNames[i]], env) {
+				return false
+			}
+		}
+
+		return true
+	case *ast.ArrayPattern:
+		arr, ok := value.(*object.Array)
+		if !ok || len(arr.Elements) < len(pattern.Elements) {
+			return false
+		}
+		if pattern.Rest == nil && len(arr.Elements) != len(pattern.Elements) {
+			return false
+		}
+
+		for i, el := range pattern.Elements {
+			if !matchPattern(el, arr.Elements[i], env) {
+				return false
+			}
+		}
+
+		if pattern.Rest != nil {
+			rest := make([]object.Object, len(arr.Elements)-len(pattern.Elements))
+			copy(rest, arr.Elements[len(pattern.Elements):])
+			env.Set(pattern.Rest.Value, newArray(rest))
+		}
+
+		return true
+	default:
+		literal := eval(pattern, env)
+
+		return literal.Type() == value.Type() && evalInfixExpression("==", literal, value) == TRUE
+	}
+}
+
+// evalTemplateLiteral concatenates the text with values of the embedded expressions,
+// strings are embedded without quotes.
+func evalTemplateLiteral(tl *ast.TemplateLiteral, env *object.Environment) object.Object {
+	var out bytes.Buffer
+
+	for _, part := range tl.Parts {
+		val := force(eval(part, env), env)
+		if isError(val) {
+			return val
+		}
+
+		if str, ok := val.(*object.String); ok {
+			out.WriteString(str.Value)
+		} else {
+			out.WriteString(val.Inspect())
+		}
+	}
+
+	return newString(out.String())
+}
+
+// force returns value of the lazy object, calling its function from given environment on first use.
+// Other objects are returned unchanged.
+func force(obj object.Object, env *object.Environment) object.Object {
+	lazy, ok := obj.(*object.Lazy)
+	if !ok {
+		return obj
+	}
+
+	if lazy.Value == nil {
+		result := applyFunction(lazy.Function, []object.Object{}, env)
+		if fun, ok := lazy.Function.(*object.Function); ok {
+			// lazy values aren't called explicitly, so the place of the call is where the function is defined
+			result = atCallSite(result, fun.Body.Token)
+		}
+		lazy.Value = force(result, env)
+	}
+
+	return lazy.Value
+}
+
 func isTruthy(obj object.Object) (val, ok bool) {
 	switch obj {
 	case FALSE:
@@ -290,13 +975,19 @@ func evalIdentifier(i *ast.Identifier, env *object.Environment) object.Object {
 		return builtin
 	}
 
-	return newError("unknown identifier: %s", i.Value)
+	if builtin, ok := envBuiltins[i.Value]; ok {
+		return builtin(env)
+	}
+
+	return newErrorAt(i.Token, "unknown identifier: %s", i.Value)
 }
 
 func evalIndexExpression(left, right object.Object) object.Object {
 	switch {
 	case left.Type() == object.ARRAY && right.Type() == object.INTEGER:
 		return evalArrayIndexExpression(left, right)
+	case left.Type() == object.BYTES && right.Type() == object.INTEGER:
+		return evalBytesIndexExpression(left, right)
 	case left.Type() == object.HASH:
 		return evalHashIndexExpression(left, right)
 	default:
@@ -304,18 +995,40 @@ func evalIndexExpression(left, right object.Object) object.Object {
 	}
 }
 
+// evalArrayIndexExpression returns element at given index or null if the index is out of the array's bounds.
+// Negative indexes count from the end of the array, e.g. -1 is the last element.
 func evalArrayIndexExpression(array, index object.Object) object.Object {
 	arrayObject := array.(*object.Array)
 	i := index.(*object.Integer).Value
 	max := int64(len(arrayObject.Elements) - 1)
 
+	if i < 0 {
+		i += max + 1
+	}
 	if i < 0 || i > max {
-		return newError("index out of boundaries")
+		return NULL
 	}
 
 	return arrayObject.Elements[i]
 }
 
+// evalBytesIndexExpression returns byte at given index as an integer or null if the index is out of range.
+// Like in arrays negative indexes count from the end.
+func evalBytesIndexExpression(b, index object.Object) object.Object {
+	bytesObject := b.(*object.Bytes)
+	i := index.(*object.Integer).Value
+	max := int64(len(bytesObject.Value) - 1)
+
+	if i < 0 {
+		i += max + 1
+	}
+	if i < 0 || i > max {
+		return NULL
+	}
+
+	return newInteger(int64(bytesObject.Value[i]))
+}
+
 func evalHashIndexExpression(hash, index object.Object) object.Object {
 	hashObject := hash.(*object.Hash)
 
@@ -323,6 +1036,9 @@ func evalHashIndexExpression(hash, index object.Object) object.Object {
 	if !ok {
 		return newError("index operator not supported: %s[%s]", hash.Type(), index.Type())
 	}
+	if !hashObject.AcceptsKey(index) {
+		return newError("key of type %s not allowed in hash of %s keys", index.Type(), hashObject.KeyType)
+	}
 
 	pair, ok := hashObject.Pairs[key.HashKey()]
 	if !ok {
@@ -332,6 +1048,54 @@ func evalHashIndexExpression(hash, index object.Object) object.Object {
 	return pair.Value
 }
 
+// evalRangeExpression creates array of integers from the range, reversed range results in empty array.
+func evalRangeExpression(re *ast.RangeExpression, env *object.Environment) object.Object {
+	start, end, err := evalRangeBounds(re, env)
+	if err != nil {
+		return err
+	}
+
+	// creating every element counts as an iteration, so huge ranges can be stopped like runaway loops
+	elements := []object.Object{}
+	for i := start; inRange(re, i, end); i++ {
+		if err := spendIteration(env); err != nil {
+			return err
+		}
+
+		elements = append(elements, newInteger(i))
+
+		if i == end {
+			break
+		}
+	}
+
+	return newArray(elements)
+}
+
+func evalRangeBounds(re *ast.RangeExpression, env *object.Environment) (int64, int64, object.Object) {
+	start := eval(re.Start, env)
+	if isError(start) {
+		return 0, 0, start
+	}
+	end := eval(re.End, env)
+	if isError(end) {
+		return 0, 0, end
+	}
+
+	startInt, ok := start.(*object.Integer)
+	endInt, ok2 := end.(*object.Integer)
+	if !ok || !ok2 {
+		return 0, 0, newError("range bounds must be INTEGER, got: %s%s%s", start.Type(), re.TokenLiteral(), end.Type())
+	}
+
+	return startInt.Value, endInt.Value, nil
+}
+
+// inRange checks if i is lower than the range's end, or equal to it if the range is inclusive.
+func inRange(re *ast.RangeExpression, i, end int64) bool {
+	return i < end || re.Inclusive && i == end
+}
+
 func evalHashLiteral(node *ast.HashLiteral, env *object.Environment) object.Object {
 	pairs := make(map[object.HashKey]object.HashPair)
 
@@ -355,7 +1119,7 @@ func evalHashLiteral(node *ast.HashLiteral, env *object.Environment) object.Obje
 		pairs[hashed] = object.HashPair{Key: key, Value: value}
 	}
 
-	return &object.Hash{Pairs: pairs}
+	return newHash(pairs, "")
 }
 
 func evalExpressions(exps []ast.Expression, env *object.Environment) []object.Object {
@@ -388,22 +1152,205 @@ func evalConstStatement(cs *ast.ConstStatement, env *object.Environment) object.
 		return newError("redeclared constant: %q in one block", cs.Name.Value)
 	}
 
+	if _, ok := env.Get(cs.Name.Value); ok {
+		warn(env, cs.Token, "constant %q shadows constant of an outer scope", cs.Name.Value)
+	}
+
 	val := eval(cs.Value, env)
 	if isError(val) {
 		return val
 	}
 
+	if cs.Type != nil {
+		if err := checkAnnotatedType(cs, val); err != nil {
+			return err
+		}
+	}
+
+	if fun, ok := val.(*object.Function); ok && fun.Name == "" {
+		fun.Name = cs.Name.Value
+	}
+
 	return env.Set(cs.Name.Value, val)
 }
 
-func applyFunction(fun object.Object, args []object.Object) object.Object {
+// types that can be used in type annotations of constants
+var annotationTypes = map[string]object.Type{
+	"Int":    object.INTEGER,
+	"Float":  object.FLOAT,
+	"String": object.STRING,
+	"Bool":   object.BOOLEAN,
+}
+
+// checkAnnotatedType checks if the value is of the type the constant was annotated with.
+func checkAnnotatedType(cs *ast.ConstStatement, val object.Object) *object.Error {
+	expected, ok := annotationTypes[cs.Type.Value]
+	if !ok {
+		return newError("unknown type: %q", cs.Type.Value)
+	}
+
+	if val.Type() != expected {
+		return newError("type mismatch: %q declared as %s, got %s", cs.Name.Value, cs.Type.Value, val.Type())
+	}
+
+	return nil
+}
+
+func evalConstGroupStatement(cgs *ast.ConstGroupStatement, env *object.Environment) object.Object {
+	var result object.Object
+
+	for _, cs := range cgs.Declarations {
+		result = evalConstStatement(cs, env)
+		if isError(result) {
+			return result
+		}
+	}
+
+	return result
+}
+
+// evalRecordStatement binds the record's name to a constructor creating instances of the record.
+func evalRecordStatement(rs *ast.RecordStatement, env *object.Environment) object.Object {
+	if _, ok := env.ShallowGet(rs.Name.Value); ok {
+		return newError("redeclared constant: %q in one block", rs.Name.Value)
+	}
+
+	fieldNames := []string{}
+	for _, f := range rs.Fields {
+		fieldNames = append(fieldNames, f.Value)
+	}
+
+	constructor := &object.Builtin{Fn: func(args ...object.Object) object.Object {
+		if len(args) != len(fieldNames) {
+			return newError("wrong number of arguments. got=%d want=%d", len(args), len(fieldNames))
+		}
+
+		fields := make(map[string]object.Object, len(fieldNames))
+		for i, name := range fieldNames {
+			fields[name] = args[i]
+		}
+
+		return &object.Record{TypeName: rs.Name.Value, FieldNames: fieldNames, Fields: fields}
+	}}
+
+	return env.Set(rs.Name.Value, constructor)
+}
+
+func evalPropertyExpression(pe *ast.PropertyExpression, env *object.Environment) object.Object {
+	receiver := force(eval(pe.Receiver, env), env)
+	if isError(receiver) {
+		return receiver
+	}
+
+	record, ok := receiver.(*object.Record)
+	if !ok {
+		return newError("property access not supported: %s.%s", receiver.Type(), pe.Property.Value)
+	}
+
+	value, ok := record.Fields[pe.Property.Value]
+	if !ok {
+		return newError("unknown field: %s.%s", record.TypeName, pe.Property.Value)
+	}
+
+	return value
+}
+
+func isComposite(obj object.Object) bool {
+	switch obj.Type() {
+	case object.ARRAY, object.HASH, object.RECORD:
+		return true
+	default:
+		return false
+	}
+}
+
+// compositesEqual compares arrays, hashes and records of the same type by their contents.
+func compositesEqual(left, right object.Object) bool {
+	if left == right {
+		return true
+	}
+
+	switch left := left.(type) {
+	case *object.Array:
+		return arraysEqual(left, right.(*object.Array))
+	case *object.Hash:
+		return hashesEqual(left, right.(*object.Hash))
+	case *object.Record:
+		return recordsEqual(left, right.(*object.Record))
+	default:
+		return false
+	}
+}
+
+// valuesEqual checks if values are equal, values of different types aren't equal.
+func valuesEqual(left, right object.Object) bool {
+	return evalInfixExpression("==", left, right) == TRUE
+}
+
+// arraysEqual checks if arrays have the same length and equal elements.
+func arraysEqual(left, right *object.Array) bool {
+	if len(left.Elements) != len(right.Elements) {
+		return false
+	}
+
+	for i, l := range left.Elements {
+		if !valuesEqual(l, right.Elements[i]) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// hashesEqual checks if hashes have the same keys with equal values.
+func hashesEqual(left, right *object.Hash) bool {
+	if len(left.Pairs) != len(right.Pairs) {
+		return false
+	}
+
+	for key, l := range left.Pairs {
+		r, ok := right.Pairs[key]
+		if !ok || !valuesEqual(l.Value, r.Value) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// recordsEqual checks if records are of the same type and have equal field values.
+func recordsEqual(left, right *object.Record) bool {
+	if left.TypeName != right.TypeName || len(left.Fields) != len(right.Fields) {
+		return false
+	}
+
+	for name, l := range left.Fields {
+		r, ok := right.Fields[name]
+		if !ok || !valuesEqual(l, r) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// applyFunction calls the function from the caller environment, whose context can cancel the call.
+func applyFunction(fun object.Object, args []object.Object, caller *object.Environment) object.Object {
 	switch function := fun.(type) {
 	case *object.Function:
-		extendedEnv := extendedFunctionEnv(function, args)
+		if function.Generator {
+			return newGenerator(function, args, caller)
+		}
+
+		extendedEnv := extendedFunctionEnv(function, args, caller)
+		if err := checkCancelled(extendedEnv); err != nil {
+			return err
+		}
+
 		evaluated := evalFunctionBody(function.Body, extendedEnv)
 
-		if isError(evaluated) {
-			return evaluated
+		if err, ok := evaluated.(*object.Error); ok {
+			return withStackFrame(err, function)
 		}
 
 		return unwrapReturnValue(evaluated)
@@ -414,6 +1361,58 @@ func applyFunction(fun object.Object, args []object.Object) object.Object {
 	}
 }
 
+// evalMethodCallExpression calls the function named by the method with the receiver as its first argument.
+func evalMethodCallExpression(mce *ast.MethodCallExpression, env *object.Environment) object.Object {
+	receiver := eval(mce.Receiver, env)
+	if isError(receiver) {
+		return receiver
+	}
+
+	method := evalIdentifier(mce.Method, env)
+	if isError(method) {
+		return method
+	}
+
+	args := evalExpressions(mce.Arguments, env)
+	if len(args) == 1 && isError(args[0]) {
+		return args[0]
+	}
+
+	return atCallSite(applyFunction(method, append([]object.Object{receiver}, args...), env), mce.Token)
+}
+
+// withStackFrame returns copy of the error recording that it propagated through a call of given function.
+// The error may be cached, e.g. by a lazy value, so it's not modified.
+// Line of the call is unknown here, it's filled in by atCallSite.
+func withStackFrame(err *object.Error, fun *object.Function) *object.Error {
+	name := fun.Name
+	if name == "" {
+		name = "anonymous function"
+	}
+
+	copied := *err
+	copied.Stack = append(append([]object.StackFrame{}, err.Stack...), object.StackFrame{Function: name})
+
+	return &copied
+}
+
+// atCallSite sets line of the token as the place of the calls the error propagated through, whose line is not known yet,
+// e.g. calls of callbacks made by built-ins get the line where the built-in was called. Other objects are returned unchanged.
+func atCallSite(obj object.Object, tok token.Token) object.Object {
+	err, ok := obj.(*object.Error)
+	if !ok || len(err.Stack) == 0 || err.Stack[len(err.Stack)-1].LineNumber != 0 {
+		return obj
+	}
+
+	copied := *err
+	copied.Stack = append([]object.StackFrame{}, err.Stack...)
+	for i := len(copied.Stack) - 1; i >= 0 && copied.Stack[i].LineNumber == 0; i-- {
+		copied.Stack[i].LineNumber = tok.LineNumber
+	}
+
+	return &copied
+}
+
 func evalFunctionBody(body *ast.BlockStatement, env *object.Environment) object.Object {
 	var result object.Object
 
@@ -428,12 +1427,27 @@ func evalFunctionBody(body *ast.BlockStatement, env *object.Environment) object.
 		}
 	}
 
+	// value of the trailing expression is returned implicitly
+	if len(body.Statements) > 0 {
+		if _, ok := body.Statements[len(body.Statements)-1].(*ast.ExpressionStatement); ok {
+			return result
+		}
+	}
+
 	return newError("missing return at the end of function body")
 }
 
-func extendedFunctionEnv(fun *object.Function, args []object.Object) *object.Environment {
+// extendedFunctionEnv binds the arguments in a new scope of the function.
+// Functions run in the context of their caller rather than the one they were defined in.
+func extendedFunctionEnv(fun *object.Function, args []object.Object, caller *object.Environment) *object.Environment {
 	env := object.NewEnclosedEnvironment(fun.Env)
 
+	ctx := caller.Context()
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	env.SetContext(ctx)
+
 	for paramIdx, param := range fun.Parameters {
 		env.Set(param.Value, args[paramIdx])
 	}
@@ -453,6 +1467,33 @@ func newError(format string, a ...interface{}) *object.Error {
 	return &object.Error{Message: fmt.Sprintf(format, a...)}
 }
 
+// newErrorAt creates an error which happened at the line of given token.
+func newErrorAt(tok token.Token, format string, a ...interface{}) *object.Error {
+	err := newError(format, a...)
+	err.LineNumber = tok.LineNumber
+
+	return err
+}
+
+// warn reports the warning at the token's line, if warnings of the environment are collected.
+func warn(env *object.Environment, tok token.Token, format string, a ...interface{}) {
+	if w := env.Warnings(); w != nil {
+		w.Add(fmt.Sprintf("line %d: ", tok.LineNumber) + fmt.Sprintf(format, a...))
+	}
+}
+
+// atLine sets line of the token as the place where the error happened, other objects are returned unchanged.
+// Calls made while evaluating the expression, e.g. of lazy values, get the line as well.
+func atLine(obj object.Object, tok token.Token) object.Object {
+	if err, ok := obj.(*object.Error); ok && err.LineNumber == 0 {
+		copied := *err
+		copied.LineNumber = tok.LineNumber
+		obj = &copied
+	}
+
+	return atCallSite(obj, tok)
+}
+
 func isError(obj object.Object) bool {
 	if obj != nil {
 		return obj.Type() == object.ERROR
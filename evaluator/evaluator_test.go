@@ -1,8 +1,17 @@
 package evaluator
 
 import (
+	"context"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/radlinskii/interpreter/ast"
 	"github.com/radlinskii/interpreter/lexer"
 	"github.com/radlinskii/interpreter/object"
 	"github.com/radlinskii/interpreter/parser"
@@ -29,6 +38,21 @@ func testEval(t *testing.T, input string) object.Object {
 	return evalProgram(program, env)
 }
 
+// testEvalOutput evaluates the input and returns its result together with what it printed.
+func testEvalOutput(t *testing.T, input string) (object.Object, string) {
+	l := lexer.New(input)
+	p := parser.New(l)
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors: %v", p.Errors())
+	}
+
+	env := object.NewEnvironment()
+	evaluated := evalProgram(program, env)
+
+	return evaluated, env.Output().Drain()
+}
+
 func TestEvalIntegerExpression(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -38,7 +62,12 @@ func TestEvalIntegerExpression(t *testing.T) {
 		{"10;", 10},
 		{"-55;", -55},
 		{"-10;", -10},
+		{"-9223372036854775808;", math.MinInt64},
+		{"-9223372036854775808 + 1;", -9223372036854775807},
+		{"-9223372036854775807 - 1;", math.MinInt64},
 		{"5 + 5 + 5 - 10;", 5},
+		{"5--3;", 8},
+		{"const a = 5; a--3;", 8},
 		{"-10 + 23;", 13},
 		{"2*2*2*2;", 16},
 		{"2 + 3 * 4;", 14},
@@ -49,6 +78,10 @@ func TestEvalIntegerExpression(t *testing.T) {
 		{"3 * 3 * 3 + 10;", 37},
 		{"3 * (3 * 3) + 10;", 37},
 		{"(5 + 10 * 2 + 15 / 3) * 2 + -10;", 50},
+		{"10 % 3;", 1},
+		{"9 % 3;", 0},
+		{"-7 % 3;", -1},
+		{"2 + 10 % 4 * 3;", 8},
 	}
 
 	for _, tt := range tests {
@@ -59,6 +92,161 @@ func TestEvalIntegerExpression(t *testing.T) {
 	}
 }
 
+func TestLogicalOperators(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"true && false;", "false"},
+		{"true and false;", "false"},
+		{"true && true;", "true"},
+		{"false || true;", "true"},
+		{"false or false;", "false"},
+		{"not true;", "false"},
+		{"not false and 1 < 2;", "true"},
+		{"false && len(1);", "false"},
+		{"true || len(1);", "true"},
+		{"false && undefinedName;", "false"},
+		{"true or undefinedName;", "true"},
+		{"true && len(1);", "\nERROR: argument to `len` not supported, got INTEGER\n"},
+		{"1 && true;", "\nERROR: line 1: expected BOOLEAN operands of &&, got: INTEGER\n"},
+		{"false or 1;", "\nERROR: line 1: expected BOOLEAN operands of ||, got: INTEGER\n"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(t, tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %q. expected=%q, got=%q", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestFloorDivision(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"7 div 2;", "3"},
+		{"-7 div 2;", "-4"},
+		{"7 div -2;", "-4"},
+		{"-7 div -2;", "3"},
+		{"6 div 3;", "2"},
+		{"-6 div 3;", "-2"},
+		{"7.5 div 2;", "3.0"},
+		{"-7.5 div 2;", "-4.0"},
+		{"1 + 7 div 2 * 2;", "7"},
+		{"7 / 2;", "3"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(t, tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %q. expected=%q, got=%q", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestTrueDivision(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"7 / 2;", "3.5"},
+		{"-7 / 2;", "-3.5"},
+		{"6 / 3;", "2"},
+		{"7 div 2;", "3"},
+		{"7.0 / 2;", "3.5"},
+		{"const half = fun(x) { x / 2 }; half(5);", "2.5"},
+		{"const half = pure fun(x) { x / 2 }; half(5);", "2.5"},
+		{"7 / 0;", "\nERROR: line 1: division by zero\n"},
+	}
+
+	for _, tt := range tests {
+		program := parser.New(lexer.New(tt.input)).ParseProgram()
+		env := object.NewEnvironment()
+		env.SetTrueDivision(true)
+
+		evaluated := Eval(program, env)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %q. expected=%q, got=%q", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestBooleanArithmetic(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"true + true;", "2"},
+		{"true - false;", "1"},
+		{"5 * false;", "0"},
+		{"2.5 + true;", "3.5"},
+		{"true div 2;", "0"},
+		{"-true;", "\nERROR: line 1: unknown operator: -BOOLEAN\n"},
+		{"true == 1;", "\nERROR: line 1: type mismatch: BOOLEAN == INTEGER\n"},
+		{"true + \"a\";", "\nERROR: line 1: type mismatch: INTEGER + STRING\n"},
+		{"const count = fun(xs) { reduce(xs, 0, fun(acc, x) { acc + x }) }; count([true, false, true]);", "2"},
+	}
+
+	for _, tt := range tests {
+		program := parser.New(lexer.New(tt.input)).ParseProgram()
+		env := object.NewEnvironment()
+		env.SetBooleanArithmetic(true)
+
+		evaluated := Eval(program, env)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %q. expected=%q, got=%q", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestStrictBooleanArithmetic(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"true + true;", "unknown operator: BOOLEAN + BOOLEAN"},
+		{"5 * false;", "type mismatch: INTEGER * BOOLEAN"},
+		{"2.5 + true;", "type mismatch: FLOAT + BOOLEAN"},
+	}
+
+	for _, tt := range tests {
+		testErrorObject(t, testEval(t, tt.input), tt.expected)
+	}
+}
+
+func TestEvalFloatExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"3.14;", "3.14"},
+		{"-3.14;", "-3.14"},
+		{"2.5 * 2;", "5.0"},
+		{"2 * 2.5;", "5.0"},
+		{"1.5 + 1.5;", "3.0"},
+		{"7 / 2.0;", "3.5"},
+		{"7.5 % 2;", "1.5"},
+		{"1.5 % 0;", "\nERROR: line 1: division by zero\n"},
+		{"0.1 - 1;", "-0.9"},
+		{"1.5 < 2;", "true"},
+		{"2.0 == 2;", "true"},
+		{"2.5 >= 2.5;", "true"},
+		{"[1.5, 2.0] < [1.5, 2.5];", "true"},
+		{"1.5 + true;", "\nERROR: line 1: type mismatch: FLOAT + BOOLEAN\n"},
+		{`1.5 + "a";`, "\nERROR: line 1: type mismatch: FLOAT + STRING\n"},
+		{"const f: Float = 2.5; f;", "2.5"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(t, tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %q. expected=%q, got=%q", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
 func TestEvalBooleanExpression(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -80,6 +268,8 @@ func TestEvalBooleanExpression(t *testing.T) {
 		{"false == true;", false},
 		{"false == false;", true},
 		{"false != true;", true},
+		{"true != false;", true},
+		{"true != true;", false},
 		{"(1 < 2) == true;", true},
 		{"(1 > 2) != true;", true},
 		{"(1 < 2) == false;", false},
@@ -231,11 +421,18 @@ func TestErrorHandling(t *testing.T) {
 		{"5 + true; 5;", "type mismatch: INTEGER + BOOLEAN"},
 		{"-true;", "unknown operator: -BOOLEAN"},
 		{"true + false;", "unknown operator: BOOLEAN + BOOLEAN"},
+		{"true < false;", "unknown operator: BOOLEAN < BOOLEAN"},
+		{"true >= false;", "unknown operator: BOOLEAN >= BOOLEAN"},
 		{"5; true + false; 10;", "unknown operator: BOOLEAN + BOOLEAN"},
 		{"if(10 > 1) { return true + false; }", "unknown operator: BOOLEAN + BOOLEAN"},
 		{"foobar;", "unknown identifier: foobar"},
 		{`"Hell" - "world";`, "unknown operator: STRING - STRING"},
 		{`5 + "worlds";`, "type mismatch: INTEGER + STRING"},
+		{`"worlds" + 5;`, "type mismatch: STRING + INTEGER"},
+		{"5 % 0;", "division by zero"},
+		{"5 / 0;", "division by zero"},
+		{"5 div 0;", "division by zero"},
+		{"5.0 div 0;", "division by zero"},
 		{`{fun(x) { return x +1; }: "Monkey"}[fun(x) { return x +1; }];`, "FUNCTION can't be used as hash key"},
 		{`{"key": "Monkey"}[fun(x) { return x +1; }];`, "index operator not supported: HASH[FUNCTION]"},
 		{`
@@ -266,7 +463,7 @@ func TestErrorHandling(t *testing.T) {
 				if (x < 10) {
 					return "duupa";
 				}
-				15;
+				const b = 15;
 			};
 
 			a(20);`,
@@ -305,6 +502,7 @@ func TestErrorHandling(t *testing.T) {
 
 			print(someFunc("oh yes"));`,
 			`redeclared constant: "x" in one block`},
+		{`const a = 1, b = 2, a = 3;`, `redeclared constant: "a" in one block`},
 		{`
 			if (1) {
 				print("1 is truthy??");
@@ -329,6 +527,80 @@ func TestErrorHandling(t *testing.T) {
 	}
 }
 
+func TestErrorLineNumbers(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"const a = 1;\nconst b = 2;\nx;", "\nERROR: line 3: unknown identifier: x\n"},
+		{"const a = 1;\n\na + true;", "\nERROR: line 3: type mismatch: INTEGER + BOOLEAN\n"},
+		{"const a = 1;\n-true;", "\nERROR: line 2: unknown operator: -BOOLEAN\n"},
+		{"1 +\n\ny;", "\nERROR: line 3: unknown identifier: y\n"},
+		{"len(1);", "\nERROR: argument to `len` not supported, got INTEGER\n"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(t, tt.input)
+
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong error for %q. expected=%q, got=%q", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestErrorStackTrace(t *testing.T) {
+	input := `
+	const inner = fun(x) {
+		x + true
+	};
+	const outer = fun(x) {
+		return inner(x);
+	};
+
+	outer(1);
+	`
+	expected := []object.StackFrame{
+		{Function: "inner", LineNumber: 6},
+		{Function: "outer", LineNumber: 9},
+	}
+
+	evaluated := testEval(t, input)
+	if !testErrorObject(t, evaluated, "type mismatch: INTEGER + BOOLEAN") {
+		return
+	}
+
+	stack := evaluated.(*object.Error).Stack
+	if len(stack) != len(expected) {
+		t.Fatalf("wrong number of stack frames, expected=%d, got=%d (%+v)", len(expected), len(stack), stack)
+	}
+
+	for i, frame := range expected {
+		if stack[i] != frame {
+			t.Errorf("wrong stack frame %d, expected=%+v, got=%+v", i, frame, stack[i])
+		}
+	}
+
+	expectedInspect := "\nERROR: line 3: type mismatch: INTEGER + BOOLEAN\n    at inner (line: 6)\n    at outer (line: 9)\n"
+	if evaluated.Inspect() != expectedInspect {
+		t.Errorf("wrong Inspect output, expected=%q, got=%q", expectedInspect, evaluated.Inspect())
+	}
+}
+
+func TestStackFramesOfCachedErrors(t *testing.T) {
+	env := object.NewEnvironment()
+	Eval(parser.New(lexer.New("const x = lazy(fun() { len(1) });\nconst f = fun() { x + 1 };")).ParseProgram(), env)
+
+	expected := "\nERROR: argument to `len` not supported, got INTEGER\n    at anonymous function (line: 1)\n    at f (line: 1)\n"
+
+	// the error is cached by the lazy value, so propagating it again mustn't add frames to it
+	for i := 0; i < 2; i++ {
+		evaluated := Eval(parser.New(lexer.New("f();")).ParseProgram(), env)
+		if evaluated.Inspect() != expected {
+			t.Errorf("wrong Inspect output of call %d, expected=%q, got=%q", i, expected, evaluated.Inspect())
+		}
+	}
+}
+
 func testErrorObject(t *testing.T, obj object.Object, expectedMessage string) bool {
 	errObj, ok := obj.(*object.Error)
 	if !ok {
@@ -353,6 +625,10 @@ func TestConstStatements(t *testing.T) {
 		{"const a = 5 * 5; a;", 25},
 		{"const a = 5; const b = a; b;", 5},
 		{"const a = 5; const b = a; const c = a + b + 5; c + 5;", 20},
+		{"const a = 1, b = 2, c = 3; a;", 1},
+		{"const a = 1, b = 2, c = 3; b;", 2},
+		{"const a = 1, b = 2, c = 3; c;", 3},
+		{"const a = 1, b = a + 1, c = b * 3; c;", 6},
 	}
 
 	for _, tt := range tests {
@@ -364,6 +640,29 @@ func TestConstStatements(t *testing.T) {
 	}
 }
 
+func TestConstTypeAnnotations(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`const x: Int = 5; x;`, "5"},
+		{`const s: String = "a" + "b"; s;`, "ab"},
+		{`const b: Bool = 1 < 2; b;`, "true"},
+		{`const a: Int = 1, b = "b", c: Bool = false; c;`, "false"},
+		{`const x: Int = "5";`, "\nERROR: type mismatch: \"x\" declared as Int, got STRING\n"},
+		{`const b: Bool = 1;`, "\nERROR: type mismatch: \"b\" declared as Bool, got INTEGER\n"},
+		{`const x: Float = 5;`, "\nERROR: type mismatch: \"x\" declared as Float, got INTEGER\n"},
+		{`const x: Number = 5;`, "\nERROR: unknown type: \"Number\"\n"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(t, tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %q. expected=%q, got=%q", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
 func TestFunctionObject(t *testing.T) {
 	input := "fun(x) { return x + 2; };"
 	expectedBody := "return (x + 2);"
@@ -387,6 +686,29 @@ func TestFunctionObject(t *testing.T) {
 	}
 }
 
+func TestFunctionObjectName(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"const add = fun(x, y) { return x + y; }; add;", "fun add(x, y) return (x + y);"},
+		{"const add = fun(x, y) { return x + y; }; const sum = add; sum;", "fun add(x, y) return (x + y);"},
+		{"fun(x) { return x; };", "fun(x) return x;"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(t, tt.input)
+		fun, ok := evaluated.(*object.Function)
+		if !ok {
+			t.Fatalf("object is not a Function. got=%T (%+v)", evaluated, evaluated)
+		}
+
+		if fun.Inspect() != tt.expected {
+			t.Errorf("wrong Inspect output. expected=%q, got=%q", tt.expected, fun.Inspect())
+		}
+	}
+}
+
 func TestFunctions(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -407,6 +729,28 @@ func TestFunctions(t *testing.T) {
 	}
 }
 
+func TestImplicitReturn(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"fun(x) { x + 1 }(1);", 2},
+		{"fun(x) { x + 1; }(2);", 3},
+		{"const f = fun(x) { const y = x * 2; y + 1 }; f(3);", 7},
+		{"const f = fun(x) { if (x > 1) { return 10; } x }; f(5) + f(1);", 11},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(t, tt.input)
+		if _, ok := evaluated.(*object.Return); ok {
+			t.Errorf("implicitly returned value leaked as *object.Return")
+		}
+		if !testIntegerObject(t, evaluated, tt.expected) {
+			return
+		}
+	}
+}
+
 func TestClosures(t *testing.T) {
 	input := `
 		const newAdder = fun(x) {
@@ -425,6 +769,90 @@ func TestClosures(t *testing.T) {
 	}
 }
 
+// closures capture the environment by reference, so constants declared after them are visible when they are called
+func TestForwardReferences(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`const f = fun() { return g(); }; const g = fun() { return 1; }; f();`, "1"},
+		{`
+			const isEven = fun(n) { n == 0 ? true : isOdd(n - 1) };
+			const isOdd = fun(n) { n == 0 ? false : isEven(n - 1) };
+			[isEven(10), isOdd(7), isEven(3)];`, "[true, true, false]"},
+		{`const f = fun() { fun() { g() } }; const h = f(); const g = fun() { 2 }; h();`, "2"},
+		{`const f = fun() { return g(); }; f(); const g = fun() { return 1; };`, "\nERROR: line 1: unknown identifier: g\n    at f (line: 1)\n"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(t, tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %q. expected=%q, got=%q", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestMapWithDifferentResultType(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`map([1, 2, 3], fun(x) { x > 1 });`, "[false, true, true]"},
+		{`map([1, 2], str);`, "[1, 2]"},
+		{`map(["a", "bc"], len);`, "[1, 2]"},
+		{`map([[1], [2, 3]], fun(xs) { { "size": len(xs) } });`, "[{size: 1}, {size: 2}]"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(t, tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %q. expected=%q, got=%q", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+
+	strs := testEval(t, `map([1, 2], str);`).(*object.Array)
+	if _, ok := strs.Elements[0].(*object.String); !ok {
+		t.Errorf("expected map to collect results of the function. got=%T", strs.Elements[0])
+	}
+}
+
+func TestObjectCount(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{`const before = objectCount(); objectCount() - before;`, 0},
+		{`const before = objectCount(); const a = [1, 2, 3]; objectCount() - before;`, 4},
+		{`const before = objectCount(); const s = "a" + "b"; objectCount() - before;`, 3},
+		{`const before = objectCount(); const t = true; const f = !t; objectCount() - before;`, 0},
+	}
+
+	for _, tt := range tests {
+		testIntegerObject(t, testEval(t, tt.input), tt.expected)
+	}
+
+	before := ObjectCount()
+	testEval(t, `map(1..10, fun(x) { x * x });`)
+	if after := ObjectCount(); after <= before {
+		t.Errorf("object count didn't increase after allocating. before=%d, after=%d", before, after)
+	}
+}
+
+func TestNestedClosuresAndReturns(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{`const outer = fun() { const inner = fun() { return 1; }; inner(); return 2; }; outer();`, 2},
+		{`const a = fun(x) { fun(y) { fun(z) { x + y + z } } }; a(1)(2)(3);`, 6},
+		{`const x = 10; const f = fun() { const x = 1; fun() { x } }; f()() + x;`, 11},
+	}
+
+	for _, tt := range tests {
+		testIntegerObject(t, testEval(t, tt.input), tt.expected)
+	}
+}
+
 func testIntegerObject(t *testing.T, obj object.Object, expected int64) bool {
 	result, ok := obj.(*object.Integer)
 	if !ok {
@@ -488,6 +916,30 @@ func TestStringConcatenation(t *testing.T) {
 	}
 }
 
+func TestReduceStrings(t *testing.T) {
+	input := `reduce(["a", "b", "c"], ">", fun(acc, x) { acc + x });`
+
+	testStringObject(t, testEval(t, input), ">abc")
+}
+
+func TestStringInterpolation(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`const name = "Ann"; const age = 30; "${name} is ${age}";`, "Ann is 30"},
+		{`"sum: ${1 + 2}, list: ${[1, "a"]}";`, "sum: 3, list: [1, a]"},
+		{`const f = fun(x) { x * 2 }; "${f(2)}${"!"}";`, "4!"},
+		{`const name = "Ann"; "\${name}";`, "${name}"},
+	}
+
+	for _, tt := range tests {
+		testStringObject(t, testEval(t, tt.input), tt.expected)
+	}
+
+	testErrorObject(t, testEval(t, `"${missing}";`), "unknown identifier: missing")
+}
+
 func TestStringComparison(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -517,6 +969,7 @@ func TestBuiltinFunctions(t *testing.T) {
 		{`len("hello world");`, 11},
 		{`len(1);`, "argument to `len` not supported, got INTEGER"},
 		{`len("one", "two");`, "wrong number of arguments. got=2 want=1"},
+		{`len();`, "wrong number of arguments. got=0 want=1"},
 		{`len([1,2,3,4]);`, 4},
 		{`len([]);`, 0},
 		{`first([1,2,3,4]);`, 1},
@@ -531,6 +984,45 @@ func TestBuiltinFunctions(t *testing.T) {
 		{`push([1,2,3]);`, "wrong number of arguments. got=1 want=2"},
 		{`push([1,2,3],3,3);`, "wrong number of arguments. got=3 want=2"},
 		{`push(true,3);`, "first argument to `push` not supported, got BOOLEAN"},
+		{`first("abc");`, "argument to `first` not supported, got STRING"},
+		{`last(1);`, "argument to `last` not supported, got INTEGER"},
+		{`rest({});`, "argument to `rest` not supported, got HASH"},
+		{`first([1], [2]);`, "wrong number of arguments. got=2 want=1"},
+		{`map([1, 2, 3], fun(x) { x * 2 });`, []int{2, 4, 6}},
+		{`map([], fun(x) { x * 2 });`, []int{}},
+		{`map([1, 2], fun(x) { x + true });`, "type mismatch: INTEGER + BOOLEAN"},
+		{`map({}, fun(x) { x });`, "first argument to `map` not supported, got HASH"},
+		{`map([1], 1);`, "second argument to `map` not supported, got INTEGER"},
+		{`map([1]);`, "wrong number of arguments. got=1 want=2"},
+		{`filter([1, 2, 3, 4], fun(x) { x > 2 });`, []int{3, 4}},
+		{`filter([3, 1, 2], fun(x) { x > 0 });`, []int{3, 1, 2}},
+		{`filter([1, 2, 3], fun(x) { x > 5 });`, []int{}},
+		{`filter([], fun(x) { x > 5 });`, []int{}},
+		{`filter([1, 2], fun(x) { x });`, "expected BOOLEAN from `filter` callback, got: INTEGER"},
+		{`filter([1, 2], fun(x) { "yes" });`, "expected BOOLEAN from `filter` callback, got: STRING"},
+		{`filter(1, fun(x) { true });`, "first argument to `filter` not supported, got INTEGER"},
+		{`filter([1], "x");`, "second argument to `filter` not supported, got STRING"},
+		{`reduce([1, 2, 3, 4], 0, fun(acc, x) { acc + x });`, 10},
+		{`reduce([], 42, fun(acc, x) { acc + x });`, 42},
+		{`reduce([1, 2], [], fun(acc, x) { push(acc, x * 2) });`, []int{2, 4}},
+		{`reduce([1, "a"], 0, fun(acc, x) { acc + x });`, "type mismatch: INTEGER + STRING"},
+		{`reduce(1, 0, fun(acc, x) { acc + x });`, "first argument to `reduce` not supported, got INTEGER"},
+		{`reduce([1], 0, 1);`, "third argument to `reduce` not supported, got INTEGER"},
+		{`reduce([1], 0);`, "wrong number of arguments. got=2 want=3"},
+		{`flatMap([1, 2], fun(x) { return [x, x * 10]; });`, []int{1, 10, 2, 20}},
+		{`flatMap([], fun(x) { return [x]; });`, []int{}},
+		{`flatMap([1, 2], fun(x) { return x; });`, "expected ARRAY from `flatMap` callback, got: INTEGER"},
+		{`flatMap(1, fun(x) { return [x]; });`, "first argument to `flatMap` not supported, got INTEGER"},
+		{`flatMap([1], 1);`, "second argument to `flatMap` not supported, got INTEGER"},
+		{`take([1, 2, 3, 4], 2);`, []int{1, 2}},
+		{`take([1, 2, 3, 4], 0);`, []int{}},
+		{`take([1, 2], 5);`, []int{1, 2}},
+		{`drop([1, 2, 3, 4], 2);`, []int{3, 4}},
+		{`drop([1, 2, 3, 4], 0);`, []int{1, 2, 3, 4}},
+		{`drop([1, 2], 5);`, []int{}},
+		{`take([1, 2], -1);`, "count must not be negative, got -1"},
+		{`drop("abc", 1);`, "first argument to `drop` not supported, got STRING"},
+		{`take([1], "1");`, "second argument to `take` not supported, got STRING"},
 	}
 
 	for _, tt := range tests {
@@ -564,59 +1056,1165 @@ func TestBuiltinFunctions(t *testing.T) {
 	}
 }
 
-func TestArrayLiteral(t *testing.T) {
-	input := `[1, 2 * 2, true, "word"];`
+func TestArrayBuiltinsDontMutate(t *testing.T) {
+	input := `
+	const arr = [1, 2, 3];
+	const pushed = push(arr, 4);
+	const tail = rest(arr);
+	[arr, pushed, tail];`
 
 	evaluated := testEval(t, input)
+	if evaluated.Inspect() != "[[1, 2, 3], [1, 2, 3, 4], [2, 3]]" {
+		t.Errorf("original array was mutated. got=%q", evaluated.Inspect())
+	}
+}
 
-	array, ok := evaluated.(*object.Array)
-	if !ok {
-		t.Fatalf("object is not Array. got=%T (%+v)", evaluated, evaluated)
+func TestFileBuiltins(t *testing.T) {
+	dir, err := ioutil.TempDir("", "junior")
+	if err != nil {
+		t.Fatalf("could not create temp dir: %s", err)
 	}
+	defer os.RemoveAll(dir)
 
-	if len(array.Elements) != 4 {
-		t.Fatalf("array has wrong number of elements, expected=4, got=%d", len(array.Elements))
+	path := filepath.Join(dir, "data.txt")
+	missing := filepath.Join(dir, "missing.txt")
+
+	evaluated := testEval(t, `writeFile("`+path+`", "hello file");`)
+	if !testNullObject(t, evaluated) {
+		return
 	}
 
-	testIntegerObject(t, array.Elements[0], 1)
-	testIntegerObject(t, array.Elements[1], 4)
-	testBooleanObject(t, array.Elements[2], true)
-	testStringObject(t, array.Elements[3], "word")
+	evaluated = testEval(t, `readFile("`+path+`");`)
+	if !testStringObject(t, evaluated, "hello file") {
+		return
+	}
+
+	evaluated = testEval(t, `readFile("`+missing+`");`)
+	if _, ok := evaluated.(*object.Error); !ok {
+		t.Errorf("object is not an Error. got=%T(%+v)", evaluated, evaluated)
+	}
+
+	evaluated = testEval(t, `readFileBytes("`+path+`")[1];`)
+	if !testIntegerObject(t, evaluated, 'e') {
+		return
+	}
+
+	testErrorObject(t, testEval(t, `readFile(1);`), "argument to `readFile` not supported, got INTEGER")
+	testErrorObject(t, testEval(t, `writeFile("a", 1);`), "second argument to `writeFile` not supported, got INTEGER")
 }
 
-func TestArrayIndexExpressions(t *testing.T) {
+func TestBytes(t *testing.T) {
 	tests := []struct {
 		input    string
-		expected interface{}
+		expected string
 	}{
-		{"[1,2,3][0];", 1},
-		{"[1,2,3][1];", 2},
-		{"[1,2,3][2];", 3},
-		{"const i = 0; [1][i];", 1},
-		{"[1,2,3][1 + 1];", 3},
-		{"const myArray = [1, 2, 3]; myArray[0];", 1},
-		{"const myArray = [1, 2, 3]; myArray[0] + myArray[2];", 4},
-		{"[1, 2, 3][-1];", "index out of boundaries"},
-		{"[1, 2, 3][3];", "index out of boundaries"},
-		{"[1, 2, 3][true];", "index operator not supported: ARRAY[BOOLEAN]"},
-		{"54[1];", "index operator not supported: INTEGER[INTEGER]"},
+		{"bytes([65, 66, 67]);", "bytes[65, 66, 67]"},
+		{`bytes("AB");`, "bytes[65, 66]"},
+		{"len(bytes([65, 66, 67]));", "3"},
+		{"len(bytes([]));", "0"},
+		{"bytes([65, 66, 255])[2];", "255"},
+		{`bytes("AB")[0];`, "65"},
+		{"bytes([65])[1];", "null"},
+		{"bytes([65, 66])[-1];", "66"},
+		{"bytes([65, 66])[-2];", "65"},
+		{"bytes([65, 66])[-3];", "null"},
+		{"bytes([256]);", "\nERROR: byte value out of range: 256\n"},
+		{"bytes([-1]);", "\nERROR: byte value out of range: -1\n"},
+		{`bytes(["a"]);`, "\nERROR: elements of array passed to `bytes` must be INTEGER, got STRING\n"},
+		{"bytes(1);", "\nERROR: argument to `bytes` not supported, got INTEGER\n"},
 	}
 
 	for _, tt := range tests {
 		evaluated := testEval(t, tt.input)
-		integer, ok := tt.expected.(int)
-		if ok {
-			testIntegerObject(t, evaluated, int64(integer))
-		} else {
-			testErrorObject(t, evaluated, tt.expected.(string))
+
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %q. expected=%q, got=%q", tt.input, tt.expected, evaluated.Inspect())
 		}
 	}
 }
 
-func TestHashLiterals(t *testing.T) {
-	input := `
-	const two = "two";
-	{
+func TestBase64(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`base64Encode("hello world");`, "aGVsbG8gd29ybGQ="},
+		{`base64Encode(bytes([0, 255]));`, "AP8="},
+		{`base64Decode("aGVsbG8gd29ybGQ=");`, "hello world"},
+		{`base64Decode(base64Encode("Junior"));`, "Junior"},
+		{`base64Decode("not base64!");`, "\nERROR: invalid base64 input: illegal base64 data at input byte 3\n"},
+		{`base64Encode(1);`, "\nERROR: argument to `base64Encode` not supported, got INTEGER\n"},
+		{`base64Decode(bytes([65]));`, "\nERROR: argument to `base64Decode` not supported, got BYTES\n"},
+		{`base64DecodeBytes("AP8=");`, "bytes[0, 255]"},
+		{`base64DecodeBytes(base64Encode(bytes([1, 2, 3])))[2];`, "3"},
+		{`base64DecodeBytes("not base64!");`, "\nERROR: invalid base64 input: illegal base64 data at input byte 3\n"},
+		{`base64DecodeBytes(1);`, "\nERROR: argument to `base64DecodeBytes` not supported, got INTEGER\n"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(t, tt.input)
+
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %q. expected=%q, got=%q", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestHashBuiltin(t *testing.T) {
+	tests := []struct {
+		left      string
+		right     string
+		sameValue bool
+	}{
+		{"1", "1", true},
+		{`"abc"`, `"ab" + "c"`, true},
+		{"[1, [true, 2.5]]", "[1, [true, 2.5]]", true},
+		{`{"a": 1, "b": [2]}`, `{"b": [2], "a": 1}`, true},
+		{"bytes([1, 2])", "bytes([1, 2])", true},
+		{"1", "2", false},
+		{"1", "true", false},
+		{"1", `"1"`, false},
+		{"1", "1.0", true},
+		{"[1, -3]", "[1.0, -3.0]", true},
+		{`{"a": 2}`, `{"a": 2.0}`, true},
+		{"1", "1.5", false},
+		{"[1, 2]", "[2, 1]", false},
+		{"[[1], 2]", "[1, [2]]", false},
+		{`{"a": 1}`, `{"a": 2}`, false},
+		{`{"a": 1, "b": 2}`, `{"a": 2, "b": 1}`, false},
+	}
+
+	for _, tt := range tests {
+		left := testEval(t, "hash("+tt.left+");")
+		right := testEval(t, "hash("+tt.right+");")
+
+		if _, ok := left.(*object.Integer); !ok {
+			t.Fatalf("hash(%s) is not Integer. got=%T (%+v)", tt.left, left, left)
+		}
+		if (left.Inspect() == right.Inspect()) != tt.sameValue {
+			t.Errorf("wrong digests of %s and %s. got=%s and %s", tt.left, tt.right, left.Inspect(), right.Inspect())
+		}
+	}
+
+	// digests must not change between runs
+	testIntegerObject(t, testEval(t, `hash("abc");`), 7746616842647733231)
+
+	testErrorObject(t, testEval(t, "hash(fun() {});"), "value of type FUNCTION can't be hashed")
+	testErrorObject(t, testEval(t, "hash([1, len]);"), "value of type BUILTIN can't be hashed")
+}
+
+func TestFlatten(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`flatten([[1, 2], [3, [4]]]);`, "[1, 2, 3, [4]]"},
+		{`flatten([1, [2, [3, [4, [5]]]]], 2);`, "[1, 2, 3, [4, [5]]]"},
+		{`flatten([1, [2, [3, [4, [5]]]]], -1);`, "[1, 2, 3, 4, 5]"},
+		{`flatten([[1], [2]], 0);`, "[[1], [2]]"},
+		{`flatten([]);`, "[]"},
+		{`flatten(1);`, "\nERROR: first argument to `flatten` not supported, got INTEGER\n"},
+		{`flatten([], "1");`, "\nERROR: second argument to `flatten` not supported, got STRING\n"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(t, tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result. expected=%q, got=%q", tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestChunk(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`chunk([1, 2, 3, 4], 2);`, "[[1, 2], [3, 4]]"},
+		{`chunk([1, 2, 3, 4, 5], 2);`, "[[1, 2], [3, 4], [5]]"},
+		{`chunk([1, 2], 5);`, "[[1, 2]]"},
+		{`chunk([], 3);`, "[]"},
+		{`chunk([1, 2], 0);`, "\nERROR: chunk size must be positive, got 0\n"},
+		{`chunk([1, 2], -1);`, "\nERROR: chunk size must be positive, got -1\n"},
+		{`chunk("12", 1);`, "\nERROR: first argument to `chunk` not supported, got STRING\n"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(t, tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result. expected=%q, got=%q", tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestRecords(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`record Point(x, y); Point(1, 2);`, "Point(x: 1, y: 2)"},
+		{`record Point(x, y); const p = Point(1, 2); p.x + p.y;`, "3"},
+		{`record Point(x, y); Point(1, 2) == Point(1, 2);`, "true"},
+		{`record Point(x, y); Point(1, 2) == Point(2, 1);`, "false"},
+		{`record Point(x, y); Point(1, 2) != Point(2, 1);`, "true"},
+		{`record Point(x, y); record Pair(x, y); Point(1, 2) == Pair(1, 2);`, "false"},
+		{`record Line(from, to); record Point(x, y); Line(Point(0, 0), Point(1, 1)) == Line(Point(0, 0), Point(1, 1));`, "true"},
+		{`record Line(from, to); record Point(x, y); Line(Point(0, 0), Point(1, 1)).to.y;`, "1"},
+		{`record Point(x, y); Point(1);`, "\nERROR: wrong number of arguments. got=1 want=2\n"},
+		{`record Point(x, y); Point(1, 2).z;`, "\nERROR: unknown field: Point.z\n"},
+		{`const a = 1; a.x;`, "\nERROR: property access not supported: INTEGER.x\n"},
+		{`record Point(x, y); record Point(x);`, "\nERROR: redeclared constant: \"Point\" in one block\n"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(t, tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %q. expected=%q, got=%q", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestMatchExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`record Point(x, y); match Point(1, 2) { Point(x, y) => x + y; _ => 0 };`, "3"},
+		{`record Point(x, y); match Point(1, 2) { Point(x, 0) => x; Point(_, y) => y };`, "2"},
+		{`record Point(x, y); record Pair(a, b); match Pair(1, 2) { Point(x, y) => x; Pair(a, b) => b };`, "2"},
+		{`match [1, 2, 3] { [h, ...t] => t; _ => [] };`, "[2, 3]"},
+		{`match [1, 2, 3] { [h, ...t] => h; _ => 0 };`, "1"},
+		{`match [] { [h, ...t] => h; _ => 0 };`, "0"},
+		{`match [1, 2] { [a] => a; [a, b] => a + b };`, "3"},
+		{`match [1] { [h, ...t] => t };`, "[]"},
+		{`match 5 { 1 => "one"; 5 => "five"; _ => "other" };`, "five"},
+		{`match -1 { -1 => "minus one"; _ => "other" };`, "minus one"},
+		{`match "a" { 1 => "int"; "a" => "string" };`, "string"},
+		{`const sum = fun(arr) { return match arr { [h, ...t] => h + sum(t); [] => 0 }; }; sum([1, 2, 3, 4]);`, "10"},
+		{`const h = 10; match [1] { [h] => h }; h;`, "10"},
+		{`match 3 { 1 => 1; 2 => 2 };`, "\nERROR: no pattern matched: 3\n"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(t, tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %q. expected=%q, got=%q", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestLazy(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`const x = lazy(fun() { return 40; }); x + 2;`, "42"},
+		{`const x = lazy(fun() { return [1, 2, 3]; }); x[1];`, "2"},
+		{`const x = lazy(fun() { return true; }); if (x) { "yes"; } else { "no"; }`, "yes"},
+		{`const x = lazy(fun() { return 1; }); -x;`, "-1"},
+		{`const x = lazy(fun() { return 1; }); x;`, "lazy"},
+		{`const x = lazy(fun() { return 1; }); x + 1; x;`, "1"},
+		{`const x = lazy(fun() { return lazy(fun() { return 2; }); }); x * 2;`, "4"},
+		{`const x = lazy(fun() { return len(1); }); x + 1;`, "\nERROR: argument to `len` not supported, got INTEGER\n    at anonymous function (line: 1)\n"},
+		{`lazy(1);`, "\nERROR: argument to `lazy` not supported, got INTEGER\n"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(t, tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %q. expected=%q, got=%q", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestLazyComputedOnce(t *testing.T) {
+	input := `const x = lazy(fun() { print("computing"); return 2; });
+x + x;
+x * 3;
+if (x > 1) { print(x + 1); }`
+
+	program := parser.New(lexer.New(input)).ParseProgram()
+	output := EvalProgram(program, object.NewEnvironment())
+
+	if count := strings.Count(output, "computing"); count != 1 {
+		t.Errorf("expected lazy function to be called once, got %d calls. output=%q", count, output)
+	}
+}
+
+func TestGenerators(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`const squares = fun(limit) { for (i in 1..limit) { yield i * i; } };
+const gen = squares(10);
+[next(gen), next(gen), next(gen), next(gen)];`, "[1, 4, 9, 16]"},
+		{`const gen = fun() { yield 1; yield 2; }(); [next(gen), next(gen), next(gen), next(gen)];`, "[1, 2, null, null]"},
+		{`const gen = fun() { yield 1; return 5; yield 2; }(); [next(gen), next(gen)];`, "[1, null]"},
+		{`const a = fun() { yield 1; }(); const b = fun() { yield 2; }(); next(b) + next(a);`, "3"},
+		{`const gen = fun() { const inner = fun() { yield 1; }; yield next(inner()) + 1; }(); next(gen);`, "2"},
+		{`fun() { yield 1; };`, "fun() yield 1;"},
+		{`const g = fun() { yield len(1); }; next(g());`, "\nERROR: argument to `len` not supported, got INTEGER\n    at g (line: 1)\n"},
+		{`yield 1;`, "\nERROR: yield statement not permitted outside generator function\n"},
+		{`next(1);`, "\nERROR: argument to `next` not supported, got INTEGER\n"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(t, tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %q. expected=%q, got=%q", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestAbandonedGenerators(t *testing.T) {
+	before := runtime.NumGoroutine()
+
+	testEval(t, `const naturals = fun() { for (i in 0..1000) { yield i; } };
+	for (i in 0..100) { next(naturals()); }`)
+
+	// goroutines of unreachable generators are stopped by finalizers run after garbage collection
+	for i := 0; i < 50 && runtime.NumGoroutine() > before; i++ {
+		runtime.GC()
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if after := runtime.NumGoroutine(); after > before {
+		t.Errorf("abandoned generators left goroutines running. before=%d, after=%d", before, after)
+	}
+}
+
+func TestCancelledGenerators(t *testing.T) {
+	before := runtime.NumGoroutine()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	env := object.NewEnvironment()
+	program := parser.New(lexer.New("const gen = fun() { while (true) { yield 1; } }(); next(gen);")).ParseProgram()
+	testIntegerObject(t, Eval(program, env.WithContext(ctx)), 1)
+	cancel()
+
+	for i := 0; i < 50 && runtime.NumGoroutine() > before; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if after := runtime.NumGoroutine(); after > before {
+		t.Errorf("cancelled generator left its goroutine running. before=%d, after=%d", before, after)
+	}
+
+	program = parser.New(lexer.New("next(gen);")).ParseProgram()
+	testNullObject(t, Eval(program, env))
+}
+
+func TestPuts(t *testing.T) {
+	tests := []struct {
+		input          string
+		expectedOutput string
+	}{
+		{`puts("a", 1, true);`, "a\n1\ntrue\n"},
+		{`puts();`, ""},
+		{`puts([1, 2]);`, "[1, 2]\n"},
+		{`print("first"); puts("second");`, "first \nsecond\n"},
+	}
+
+	for _, tt := range tests {
+		evaluated, output := testEvalOutput(t, tt.input)
+		testNullObject(t, evaluated)
+
+		if output != tt.expectedOutput {
+			t.Errorf("wrong output for %q. expected=%q, got=%q", tt.input, tt.expectedOutput, output)
+		}
+	}
+}
+
+func TestWrite(t *testing.T) {
+	tests := []struct {
+		input          string
+		expectedOutput string
+	}{
+		{`write("a", 1, true);`, "a1true"},
+		{`write();`, ""},
+		{`write("x"); write("y");`, "xy"},
+		{`write([1, 2], "\n");`, "[1, 2]\n"},
+		{`print("first"); write("second"); puts("third");`, "first \nsecondthird\n"},
+	}
+
+	for _, tt := range tests {
+		evaluated, output := testEvalOutput(t, tt.input)
+		testNullObject(t, evaluated)
+
+		if output != tt.expectedOutput {
+			t.Errorf("wrong output for %q. expected=%q, got=%q", tt.input, tt.expectedOutput, output)
+		}
+	}
+}
+
+func TestReadNumbers(t *testing.T) {
+	tests := []struct {
+		input    string
+		stdin    string
+		expected string
+	}{
+		{"readInt();", "42\n", "42"},
+		{"readInt() + readInt();", " -7 \r\n10", "3"},
+		{"readFloat();", "2.5\n", "2.5"},
+		{"readFloat();", "3\n", "3.0"},
+		{"[readInt(), readInt()];", "1\n", "[1, null]"},
+		{"readFloat();", "", "null"},
+		{"readInt();", "abc\n", "\nERROR: could not parse \"abc\" as integer\n"},
+		{"readInt();", "1.5\n", "\nERROR: could not parse \"1.5\" as integer\n"},
+		{"readFloat();", "x\n", "\nERROR: could not parse \"x\" as float\n"},
+		{"readInt(1);", "", "\nERROR: wrong number of arguments. got=1 want=0\n"},
+	}
+
+	if evaluated := testEval(t, "readInt();"); evaluated != NULL {
+		t.Errorf("expected null without input. got=%s", evaluated.Inspect())
+	}
+
+	for _, tt := range tests {
+		program := parser.New(lexer.New(tt.input)).ParseProgram()
+		env := object.NewEnvironment()
+		env.SetInput(strings.NewReader(tt.stdin))
+
+		evaluated := Eval(program, env)
+
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %q with input %q. expected=%q, got=%q", tt.input, tt.stdin, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestTimes(t *testing.T) {
+	tests := []struct {
+		input          string
+		expectedOutput string
+	}{
+		{`times(3, fun(i) { print(i); });`, "0 \n1 \n2 \nnull"},
+		{`const n = 2; n.times(fun(i) { print(i * 10); });`, "0 \n10 \nnull"},
+		{`5.times(fun(i) { print(i); });`, "0 \n1 \n2 \n3 \n4 \nnull"},
+		{`2.5.times(fun(i) { print(i); });`, "\nERROR: first argument to `times` not supported, got FLOAT\n"},
+		{`times(0, fun(i) { print(i); });`, "null"},
+		{`times(-1, fun(i) { print(i); });`, "\nERROR: count must not be negative, got -1\n"},
+		{`times("3", fun(i) { print(i); });`, "\nERROR: first argument to `times` not supported, got STRING\n"},
+		{`times(3, 3);`, "\nERROR: second argument to `times` not supported, got INTEGER\n"},
+		{`times(3, fun(i) { print(i); len(i); });`, "0 \n\nERROR: argument to `len` not supported, got INTEGER\n    at anonymous function (line: 1)\n"},
+	}
+
+	for _, tt := range tests {
+		program := parser.New(lexer.New(tt.input)).ParseProgram()
+		output := EvalProgram(program, object.NewEnvironment())
+
+		if output != tt.expectedOutput {
+			t.Errorf("wrong output for %q. expected=%q, got=%q", tt.input, tt.expectedOutput, output)
+		}
+	}
+}
+
+func TestTypeBuiltin(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"type(1);", "INTEGER"},
+		{"type(1.5);", "FLOAT"},
+		{"type(true);", "BOOLEAN"},
+		{`type("a");`, "STRING"},
+		{"type(bytes([1]));", "BYTES"},
+		{"type([][0]);", "NULL"},
+		{"type(fun() { return; }());", "VOID"},
+		{"type(fun(x) { x });", "FUNCTION"},
+		{"type(len);", "BUILTIN"},
+		{"type([1]);", "ARRAY"},
+		{"type({});", "HASH"},
+		{"type(currentEnv());", "ENV"},
+		{"record P(x); type(P(1));", "RECORD"},
+		{"type(lazy(fun() { 1 }));", "LAZY"},
+		{"type(fun() { yield 1; }());", "GENERATOR"},
+		{"type(spawn(fun() { 1 }));", "TASK"},
+		{"type(channel());", "CHANNEL"},
+		{"type(1, 2);", "\nERROR: wrong number of arguments. got=2 want=1\n"},
+		{"type();", "\nERROR: wrong number of arguments. got=0 want=1\n"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(t, tt.input)
+
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %q. expected=%q, got=%q", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestStrBuiltin(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"str(42);", "42"},
+		{"str(-1.5);", "-1.5"},
+		{"str(true);", "true"},
+		{"str([][0]);", "null"},
+		{"str([1, 2]);", "[1, 2]"},
+		{`str("a");`, "a"},
+		{`"answer: " + str(42);`, "answer: 42"},
+	}
+
+	for _, tt := range tests {
+		testStringObject(t, testEval(t, tt.input), tt.expected)
+	}
+
+	testErrorObject(t, testEval(t, "str();"), "wrong number of arguments. got=0 want=1")
+	testErrorObject(t, testEval(t, "str(1, 2);"), "wrong number of arguments. got=2 want=1")
+}
+
+func TestSplitBuiltin(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`split("a,b,c", ",");`, "[a, b, c]"},
+		{`len(split("a,b,c", ","));`, "3"},
+		{`split("a::b::", "::");`, "[a, b, ]"},
+		{`split("abc", "");`, "[a, b, c]"},
+		{`split("abc", ";");`, "[abc]"},
+		{`len(split("", ","));`, "1"},
+		{`split(1, ",");`, "\nERROR: first argument to `split` not supported, got INTEGER\n"},
+		{`split("a", 1);`, "\nERROR: second argument to `split` not supported, got INTEGER\n"},
+		{`split("a");`, "\nERROR: wrong number of arguments. got=1 want=2\n"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(t, tt.input)
+
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %q. expected=%q, got=%q", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestJoinBuiltin(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`join(["a", "b", "c"], "-");`, "a-b-c"},
+		{`join(["a"], "-");`, "a"},
+		{`join([], "-");`, ""},
+		{`join(["a", "b"], "");`, "ab"},
+		{`join(split("a,b", ","), ", ");`, "a, b"},
+		{`join(["a", 1], "-");`, "\nERROR: elements of array passed to `join` must be STRING, got INTEGER\n"},
+		{`join("ab", "-");`, "\nERROR: first argument to `join` not supported, got STRING\n"},
+		{`join(["a"], 1);`, "\nERROR: second argument to `join` not supported, got INTEGER\n"},
+		{`join(["a"]);`, "\nERROR: wrong number of arguments. got=1 want=2\n"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(t, tt.input)
+
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %q. expected=%q, got=%q", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestContainsAndIndexBuiltins(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"contains([1, 2, 3], 2);", "true"},
+		{"contains([1, 2, 3], 4);", "false"},
+		{"contains([], 1);", "false"},
+		{"contains([[1, 2], [3, 4]], [1, 2]);", "true"},
+		{"contains([[1, 2], [3, 4]], [2, 1]);", "false"},
+		{`contains([{"a": [1]}], {"a": [1]});`, "true"},
+		{`contains([1, "1"], "1");`, "true"},
+		{"record P(x); contains([P(1), P(2)], P(2));", "true"},
+		{"index([[1, 2], [3, 4]], [3, 4]);", "1"},
+		{`index([{"a": 1}, {"b": 2}], {"b": 2});`, "1"},
+		{"index([1, 2, 1], 1);", "0"},
+		{"index([1, 2], 3);", "null"},
+		{"contains(1, 1);", "\nERROR: first argument to `contains` not supported, got INTEGER\n"},
+		{`index("abc", "b");`, "\nERROR: first argument to `index` not supported, got STRING\n"},
+		{"index([1]);", "\nERROR: wrong number of arguments. got=1 want=2\n"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(t, tt.input)
+
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %q. expected=%q, got=%q", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestIntBuiltin(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`int("123");`, "123"},
+		{`int("-7");`, "-7"},
+		{`int("+7");`, "7"},
+		{"int(3.9);", "3"},
+		{"int(-3.9);", "-3"},
+		{"int(5);", "5"},
+		{`int("abc");`, "\nERROR: could not parse \"abc\" as integer\n"},
+		{`int("1.5");`, "\nERROR: could not parse \"1.5\" as integer\n"},
+		{`int("");`, "\nERROR: could not parse \"\" as integer\n"},
+		{`int("99999999999999999999");`, "\nERROR: could not parse \"99999999999999999999\" as integer\n"},
+		{"int(10000000000.0 * 10000000000.0);", "\nERROR: float out of integer range: 100000000000000000000.0\n"},
+		{"int(true);", "\nERROR: argument to `int` not supported, got BOOLEAN\n"},
+		{"int();", "\nERROR: wrong number of arguments. got=0 want=1\n"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(t, tt.input)
+
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %q. expected=%q, got=%q", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestTypeSwitchExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`const describe = fun(x) {
+			typeswitch (x) { case Int: x + 1; case String: "string"; default: "other" }
+		};
+		describe(41);`, "42"},
+		{`const describe = fun(x) {
+			typeswitch (x) { case Int: x + 1; case String: "string"; default: "other" }
+		};
+		describe(fun() { 1 });`, "other"},
+		{`typeswitch ("a") { case Int: 1; case String: 2 };`, "2"},
+		{`typeswitch ([1]) { case Array: 1; case Array: 2 };`, "1"},
+		{`typeswitch (len) { case Function: 1; case Builtin: 2 };`, "2"},
+		{`typeswitch (lazy(fun() { 1 })) { case Lazy: 1; case Int: 2 };`, "2"},
+		{`typeswitch (true) { case Int: 1 };`, "\nERROR: line 1: no case matched type: BOOLEAN\n"},
+		{`typeswitch (1) { case Int: 1; case Integer: 2 };`, "\nERROR: line 1: unknown type: \"Integer\"\n"},
+		{`typeswitch (missing) { default: 1 };`, "\nERROR: line 1: unknown identifier: missing\n"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(t, tt.input)
+
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %q. expected=%q, got=%q", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestSpawn(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"const ch = channel(); spawn(fun() { send(ch, 6 * 7) }); recv(ch);", "42"},
+		{"const ch = channel(1); send(ch, [1, 2]); recv(ch);", "[1, 2]"},
+		{"const a = 20; const task = spawn(fun() { a + 1 }); wait(task) * 2;", "42"},
+		{"const task = spawn(len); wait(task);", "\nERROR: wrong number of arguments. got=0 want=1\n"},
+		{`const ch = channel();
+		const worker = fun(n) { fun() { send(ch, n * n) } };
+		spawn(worker(2));
+		spawn(worker(3));
+		recv(ch) + recv(ch);`, "13"},
+		{"spawn(1);", "\nERROR: argument to `spawn` not supported, got INTEGER\n"},
+		{"channel(-1);", "\nERROR: channel capacity must not be negative, got -1\n"},
+		{`channel("a");`, "\nERROR: argument to `channel` not supported, got STRING\n"},
+		{"send(1, 2);", "\nERROR: first argument to `send` not supported, got INTEGER\n"},
+		{"recv([]);", "\nERROR: argument to `recv` not supported, got ARRAY\n"},
+		{"wait(channel());", "\nERROR: argument to `wait` not supported, got CHANNEL\n"},
+		{"const ch = channel(); const task = spawn(fun() { recv(ch) * 2 }); send(ch, 21); wait(task);", "42"},
+		{"const ch = channel(2); send(ch, 1); send(ch, 2); recv(ch) + recv(ch);", "3"},
+		{`const ch = channel();
+		const results = channel();
+		spawn(fun() { send(results, recv(ch) + 1) });
+		spawn(fun() { send(results, recv(ch) + 1) });
+		send(ch, 1);
+		send(ch, 2);
+		recv(results) + recv(results);`, "5"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(t, tt.input)
+
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %q. expected=%q, got=%q", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestDeadlocks(t *testing.T) {
+	tests := []string{
+		"recv(channel());",
+		"send(channel(), 1);",
+		"const ch = channel(1); send(ch, 1); send(ch, 2);",
+		"const ch = channel(); wait(spawn(fun() { recv(ch) }));",
+		"const ch = channel(); const other = channel(); spawn(fun() { send(other, recv(ch)) }); recv(other);",
+	}
+
+	for _, input := range tests {
+		evaluated := testEval(t, input)
+
+		// it depends on scheduling which of the waiting functions notices the deadlock
+		err, ok := evaluated.(*object.Error)
+		if !ok || err.Message != "deadlock: all functions are waiting for channels or tasks" {
+			t.Errorf("expected deadlock of %q. got=%q", input, evaluated.Inspect())
+		}
+	}
+}
+
+// run with -race to detect unguarded output
+func TestSpawnedOutput(t *testing.T) {
+	input := `const done = channel();
+	const worker = fun() { for (i in 0..10) { print("worker"); } send(done, true) };
+	spawn(worker);
+	spawn(worker);
+	for (i in 0..10) { print("main"); }
+	recv(done) && recv(done);`
+
+	evaluated, output := testEvalOutput(t, input)
+	testBooleanObject(t, evaluated, true)
+
+	if strings.Count(output, "worker \n") != 20 || strings.Count(output, "main \n") != 10 {
+		t.Errorf("wrong output of spawned functions. got=%q", output)
+	}
+}
+
+// run with -race to detect unguarded bindings and budget
+func TestSpawnedSharedState(t *testing.T) {
+	input := `const e = currentEnv();
+	const done = channel();
+	const worker = fun(name) { fun() { for (i in 0..50) { envSet(e, name + str(i), i); } send(done, true) } };
+	spawn(worker("a"));
+	spawn(worker("b"));
+	for (i in 0..50) { envSet(e, "c" + str(i), i); envGet(e, "c0"); }
+	recv(done) && recv(done);
+	envGet(e, "a49") + envGet(e, "b49") + envGet(e, "c49");`
+
+	program := parser.New(lexer.New(input)).ParseProgram()
+	env := object.NewEnvironment()
+	budget := &object.IterationBudget{Max: 1000}
+	env.SetIterationBudget(budget)
+
+	testIntegerObject(t, Eval(program, env), 147)
+
+	if budget.Used != 150 {
+		t.Errorf("wrong number of iterations spent by spawned functions. expected=150, got=%d", budget.Used)
+	}
+}
+
+func TestCompose(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`compose(fun(x) { x + "f" }, fun(x) { x + "g" }, fun(x) { x + "h" })("x");`, "xhgf"},
+		{"compose(fun(x) { x * 2 }, fun(x) { x + 1 })(5);", "12"},
+		{"compose(fun(x) { x * 2 }, fun(a, b) { a - b })(5, 1);", "8"},
+		{"compose(len, rest)([1, 2, 3]);", "2"},
+		{"compose()(1);", "1"},
+		{"compose(fun(x) { x }, 1);", "\nERROR: arguments to `compose` must be functions, got INTEGER\n"},
+		{"compose(len, fun(x) { x })(true);", "\nERROR: argument to `len` not supported, got BOOLEAN\n"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(t, tt.input)
+
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %q. expected=%q, got=%q", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestTemplate(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`template("{name} is {age}", {"name": "sam", "age": 30});`, "sam is 30"},
+		{`template("no placeholders", {});`, "no placeholders"},
+		{`template("{a}{a}", {"a": [1]});`, "[1][1]"},
+		{`template("{{name}} is {name}", {"name": "sam"});`, "{name} is sam"},
+		{`template("}", {});`, "}"},
+		{`template("{name} is {age}", {"name": "sam"});`, "\nERROR: no value for placeholder \"age\" in template\n"},
+		{`template("{name", {"name": "sam"});`, "\nERROR: placeholder not terminated in template: \"{name\"\n"},
+		{`template(1, {});`, "\nERROR: first argument to `template` not supported, got INTEGER\n"},
+		{`template("{a}", [1]);`, "\nERROR: second argument to `template` not supported, got ARRAY\n"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(t, tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %q. expected=%q, got=%q", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestIntegerFormattingBuiltins(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`hex(255);`, "0xff"},
+		{`hex(0);`, "0x0"},
+		{`hex(-255);`, "-0xff"},
+		{`bin(5);`, "0b101"},
+		{`bin(-5);`, "-0b101"},
+		{`oct(8);`, "0o10"},
+		{`oct(-8);`, "-0o10"},
+		{`hex("ff");`, "\nERROR: argument to `hex` not supported, got STRING\n"},
+		{`bin();`, "\nERROR: wrong number of arguments. got=0 want=1\n"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(t, tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %q. expected=%q, got=%q", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestBitCountBuiltins(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`popcount(0);`, "0"},
+		{`popcount(7);`, "3"},
+		{`popcount(256);`, "1"},
+		{`popcount(-1);`, "64"},
+		{`clz(1);`, "63"},
+		{`clz(0);`, "64"},
+		{`clz(-1);`, "0"},
+		{`ctz(8);`, "3"},
+		{`ctz(0);`, "64"},
+		{`ctz(-2);`, "1"},
+		{`popcount("7");`, "\nERROR: argument to `popcount` not supported, got STRING\n"},
+		{`clz(1, 2);`, "\nERROR: wrong number of arguments. got=2 want=1\n"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(t, tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %q. expected=%q, got=%q", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestArrayLiteral(t *testing.T) {
+	input := `[1, 2 * 2, true, "word"];`
+
+	evaluated := testEval(t, input)
+
+	array, ok := evaluated.(*object.Array)
+	if !ok {
+		t.Fatalf("object is not Array. got=%T (%+v)", evaluated, evaluated)
+	}
+
+	if len(array.Elements) != 4 {
+		t.Fatalf("array has wrong number of elements, expected=4, got=%d", len(array.Elements))
+	}
+
+	testIntegerObject(t, array.Elements[0], 1)
+	testIntegerObject(t, array.Elements[1], 4)
+	testBooleanObject(t, array.Elements[2], true)
+	testStringObject(t, array.Elements[3], "word")
+}
+
+func TestConditionalExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"const c = true; const cond = false; [if (c) { 1 } else { 2 }, cond ? 3 : 4];", "[1, 4]"},
+		{"[if (1 > 2) { 1 }];", "[null]"},
+		{"const x = 5; x > 3 ? x < 4 ? 1 : 2 : 3;", "2"},
+		{"const f = fun(n) { n == 0 ? 1 : n * f(n - 1) }; f(5);", "120"},
+		{`{"a": true ? 1 : 2}["a"];`, "1"},
+		{"true ? 1 : 1 / 0;", "1"},
+		{"1 ? 2 : 3;", "\nERROR: expected BOOLEAN as condition in conditional expression got: INTEGER\n"},
+		{"[if (1) { 2 }];", "\nERROR: expected BOOLEAN as condition in if-expression got: INTEGER\n"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(t, tt.input)
+
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("expected=%q, got=%q", tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestCompositeEquality(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{"[1, 2] == [1, 2];", true},
+		{"[1, 2] != [1, 2];", false},
+		{"[] == [];", true},
+		{"const a = [1]; a == a;", true},
+		{"[1, 2] == [1, 2, 3];", false},
+		{"[1, 2, 3] != [1, 2];", true},
+		{"[1, 2] == [2, 1];", false},
+		{"[[1, [2]], 3] == [[1, [2]], 3];", true},
+		{"[[1, [2]], 3] == [[1, [4]], 3];", false},
+		{`[1, "a"] == [1, true];`, false},
+		{"[1] == [1.0];", true},
+		{`{"a": 1, "b": [2]} == {"b": [2], "a": 1};`, true},
+		{`{"a": 1} == {"a": 2};`, false},
+		{`{"a": 1} == {"b": 1};`, false},
+		{`{"a": 1} != {"a": 1, "b": 2};`, true},
+		{"record P(x); P([1]) == P([1]);", true},
+	}
+
+	for _, tt := range tests {
+		testBooleanObject(t, testEval(t, tt.input), tt.expected)
+	}
+}
+
+func TestArrayOrdering(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"[1, 2, 3] < [1, 2, 4];", true},
+		{"[1, 2, 3] > [1, 2, 4];", false},
+		{"[1, 2] < [1, 2, 0];", true},
+		{"[1, 2, 0] <= [1, 2];", false},
+		{"[] < [1];", true},
+		{"[1, 2] <= [1, 2];", true},
+		{"[1, 2] >= [1, 2];", true},
+		{"[2] > [1, 9, 9];", true},
+		{"[[1, 2], 3] < [[1, 3], 0];", true},
+		{`["a", "b"] < ["a", "c"];`, true},
+		{`[1, 2] < [1, "2"];`, "incomparable elements: INTEGER and STRING"},
+		{`[true] < [false];`, "incomparable elements: BOOLEAN and BOOLEAN"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(t, tt.input)
+		boolean, ok := tt.expected.(bool)
+		if ok {
+			testBooleanObject(t, evaluated, boolean)
+		} else {
+			testErrorObject(t, evaluated, tt.expected.(string))
+		}
+	}
+}
+
+func TestRangeExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"1..5;", "[1, 2, 3, 4]"},
+		{"1..=5;", "[1, 2, 3, 4, 5]"},
+		{"const n = 3; 0..n;", "[0, 1, 2]"},
+		{"-2..=1;", "[-2, -1, 0, 1]"},
+		{"5..1;", "[]"},
+		{"5..=1;", "[]"},
+		{"3..3;", "[]"},
+		{"3..=3;", "[3]"},
+		{`1.."5";`, "\nERROR: range bounds must be INTEGER, got: INTEGER..STRING\n"},
+		{`true..=5;`, "\nERROR: range bounds must be INTEGER, got: BOOLEAN..=INTEGER\n"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(t, tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result. expected=%q, got=%q", tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestForInStatements(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"const f = fun(arr) { for (x in arr) { if (x > 2) { return x; } } return 0; }; f([1, 2, 3, 4]);", 3},
+		{"const f = fun(arr) { for (x in arr) { if (x > 2) { return x; } } return 0; }; f([]);", 0},
+		{"fun() { for (i in 1..=3) { if (i == 3) { return i; } } return 0; }();", 3},
+		{"fun() { for (i in 1..3) { if (i == 3) { return i; } } return 0; }();", 0},
+		{"fun() { for (i in 0..9223372036854775807) { if (i == 5) { return i; } } return -1; }();", 5},
+		{"for (x in [1]) { const y = x; } y;", "unknown identifier: y"},
+		{"for (x in [1]) { x + true; }", "type mismatch: INTEGER + BOOLEAN"},
+		{"for (x in 5) { x; }", "expected ARRAY in for-in loop, got: INTEGER"},
+		{"for (x in 1..true) { x; }", "range bounds must be INTEGER, got: INTEGER..BOOLEAN"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(t, tt.input)
+		integer, ok := tt.expected.(int)
+		if ok {
+			testIntegerObject(t, evaluated, int64(integer))
+		} else {
+			testErrorObject(t, evaluated, tt.expected.(string))
+		}
+	}
+}
+
+func TestForStatements(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"fun() { for (s = [0, 1]; true; [s[0] + s[1], s[1] + 1]) { if (s[1] > 10) { return s[0]; } } }();", 55},
+		{"fun() { for (i = 0; i < 10; i + 1) { if (i * i > 20) { return i; } } return -1; }();", 5},
+		{"fun() { for (i = 0; i < 3; i + 1) { for (j = i; j < 3; j + 1) { if (i + j == 3) { return i * 10 + j; } } } return -1; }();", 12},
+		{"for (i = 0; i < 0; i + 1) { 1 + true; }", nil},
+		{"for (i = 0; i < 3; i + 1) { const x = i; } i;", "unknown identifier: i"},
+		{"for (i = 0; i; i + 1) { i; }", "expected BOOLEAN as condition in for loop got: INTEGER"},
+		{"for (i = 0; i < 3; i + true) { i; }", "type mismatch: INTEGER + BOOLEAN"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(t, tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			testErrorObject(t, evaluated, expected)
+		default:
+			testNullObject(t, evaluated)
+		}
+	}
+}
+
+func TestWhileStatements(t *testing.T) {
+	counter := "const counter = fun() { for (i in 0..100) { yield i; } }();"
+
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{counter + "fun() { while (true) { const i = next(counter); if (i == 3) { return i; } } }();", 3},
+		{counter + "fun() { while (next(counter) < 5) { const x = 1; } return next(counter); }();", 6},
+		{"while (false) { 1; }", nil},
+		{"while (1) { 1; }", "expected BOOLEAN as condition in while loop got: INTEGER"},
+		{"while (true) { 1 + true; }", "type mismatch: INTEGER + BOOLEAN"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(t, tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			testErrorObject(t, evaluated, expected)
+		default:
+			testNullObject(t, evaluated)
+		}
+	}
+}
+
+func TestIterationBudget(t *testing.T) {
+	nested := "for (i in 0..3) { for (j in [1, 2, 3]) { j; } }"
+
+	tests := []struct {
+		input       string
+		max         int64
+		expectedErr bool
+	}{
+		{"while (true) { 1; }", 100, true},
+		{nested, 12, false},
+		{nested, 11, true},
+		{"const f = fun() { for (i in 0..5) { i; } return 0; }; f(); f();", 10, false},
+		{"const f = fun() { for (i in 0..5) { i; } return 0; }; f(); f(); f();", 10, true},
+		{"len(0..10);", 10, false},
+		{"len(0..=10);", 10, true},
+		{"len(0..10000000000);", 100, true},
+	}
+
+	for _, tt := range tests {
+		program := parser.New(lexer.New(tt.input)).ParseProgram()
+		env := object.NewEnvironment()
+		env.SetIterationBudget(&object.IterationBudget{Max: tt.max})
+
+		evaluated := Eval(program, env)
+		if tt.expectedErr {
+			testErrorObject(t, evaluated, "iteration budget exceeded")
+		} else if isError(evaluated) {
+			t.Errorf("unexpected error for %q with budget %d: %s", tt.input, tt.max, evaluated.Inspect())
+		}
+	}
+}
+
+func TestForInRangeAllocations(t *testing.T) {
+	parse := func(input string) *ast.Program {
+		return parser.New(lexer.New(input)).ParseProgram()
+	}
+
+	lazy := parse("for (i in 0..1000) { i; }")
+	materialized := parse("const r = 0..1000; for (i in r) { i; }")
+
+	lazyAllocs := testing.AllocsPerRun(10, func() {
+		evalProgram(lazy, object.NewEnvironment())
+	})
+	materializedAllocs := testing.AllocsPerRun(10, func() {
+		evalProgram(materialized, object.NewEnvironment())
+	})
+
+	if lazyAllocs >= materializedAllocs {
+		t.Errorf("iterating range allocates as much as iterating an array: %v >= %v", lazyAllocs, materializedAllocs)
+	}
+}
+
+func BenchmarkForInRange(b *testing.B) {
+	program := parser.New(lexer.New("for (i in 0..1000000) { i; }")).ParseProgram()
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		evalProgram(program, object.NewEnvironment())
+	}
+}
+
+func TestArrayIndexExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"[1,2,3][0];", 1},
+		{"[1,2,3][1];", 2},
+		{"[1,2,3][2];", 3},
+		{"const i = 0; [1][i];", 1},
+		{"[1,2,3][1 + 1];", 3},
+		{"const myArray = [1, 2, 3]; myArray[0];", 1},
+		{"const myArray = [1, 2, 3]; myArray[0] + myArray[2];", 4},
+		{"[10, 20, 30][-1];", 30},
+		{"[10, 20, 30][-3];", 10},
+		{"[10, 20, 30][-4];", nil},
+		{"[][-1];", nil},
+		{"[1, 2, 3][3];", nil},
+		{"[1, 2, 3][5];", nil},
+		{"[][0];", nil},
+		{"[1, 2, 3][true];", "index operator not supported: ARRAY[BOOLEAN]"},
+		{`[1, 2, 3]["1"];`, "index operator not supported: ARRAY[STRING]"},
+		{"54[1];", "index operator not supported: INTEGER[INTEGER]"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(t, tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			testErrorObject(t, evaluated, expected)
+		default:
+			testNullObject(t, evaluated)
+		}
+	}
+}
+
+func TestHashLiterals(t *testing.T) {
+	input := `
+	const two = "two";
+	{
 		"one": 10 - 9,
 		two: 1 + 1,
 		"thr" + "ee": 6 / 2,
@@ -666,6 +2264,134 @@ func TestHashIndexExpressions(t *testing.T) {
 		{`{5: 10}[5];`, 10},
 		{`{true: 5}[true];`, 5},
 		{`{false: 5}[false];`, 5},
+		{`const a = 1; const b = 2; {(a + b): 5}[3];`, 5},
+		{`{(1 < 2): 5}[true];`, 5},
+		{`{([1] + [2]): 5};`, "unknown operator: ARRAY + ARRAY"},
+		{`{(fun() { 1 }): 5};`, "FUNCTION can't be used as hash key"},
+		{`const a = [1]; {(a): 5};`, "ARRAY can't be used as hash key"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(t, tt.input)
+		integer, ok := tt.expected.(int)
+		if ok {
+			testIntegerObject(t, evaluated, int64(integer))
+		} else {
+			testErrorObject(t, evaluated, tt.expected.(string))
+		}
+	}
+}
+
+func TestTypedHash(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`put(typedHash("STRING"), "a", 1)["a"];`, 1},
+		{`put(put({}, 1, 2), "a", 3)["a"];`, 3},
+		{`put(put(typedHash("INTEGER"), 1, 2), 3, 4)[1];`, 2},
+		{`put(typedHash("STRING"), 1, 2);`, "key of type INTEGER not allowed in hash of STRING keys"},
+		{`typedHash("STRING")[true];`, "key of type BOOLEAN not allowed in hash of STRING keys"},
+		{`put(typedHash("STRING"), [], 2);`, "ARRAY can't be used as hash key"},
+		{`typedHash("ARRAY");`, "ARRAY can't be used as hash key type"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(t, tt.input)
+		integer, ok := tt.expected.(int)
+		if ok {
+			testIntegerObject(t, evaluated, int64(integer))
+		} else {
+			testErrorObject(t, evaluated, tt.expected.(string))
+		}
+	}
+}
+
+func TestGroupBy(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`const g = groupBy([1, 2, 3, 4], fun(x) { x - x / 2 * 2 }); g[0];`, "[2, 4]"},
+		{`const g = groupBy([1, 2, 3, 4], fun(x) { x - x / 2 * 2 }); g[1];`, "[1, 3]"},
+		{`const g = groupBy(["a", "abcd", "ab", "abcde"], fun(s) { if (len(s) > 3) { return "long"; } "short" }); g["long"];`, "[abcd, abcde]"},
+		{`const g = groupBy(["a", "abcd", "ab", "abcde"], fun(s) { if (len(s) > 3) { return "long"; } "short" }); g["short"];`, "[a, ab]"},
+		{`groupBy([], fun(x) { x });`, "{}"},
+		{`groupBy([1], fun(x) { [x] });`, "\nERROR: ARRAY can't be used as hash key\n"},
+		{`groupBy([1], 2);`, "\nERROR: second argument to `groupBy` not supported, got INTEGER\n"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(t, tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result. expected=%q, got=%q", tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestEnvironmentBuiltins(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`envSet(currentEnv(), "answer", 42); answer;`, 42},
+		{`const a = 5; envGet(currentEnv(), "a");`, 5},
+		{`const name = "dyn"; envSet(currentEnv(), name + "amic", 7); dynamic;`, 7},
+		{`const f = fun(x) { envGet(currentEnv(), "x") }; f(3);`, 3},
+		{`const f = fun() { envSet(currentEnv(), "local", 1); }; f(); local;`, "unknown identifier: local"},
+		{`currentEnv().envSet("b", 2); currentEnv().envGet("b");`, 2},
+		{`envGet(currentEnv(), "missing");`, "unknown identifier: missing"},
+		{`const a = 1; envSet(currentEnv(), "a", 2);`, `redeclared constant: "a" in one block`},
+		{`envGet({}, "a");`, "first argument to `envGet` not supported, got HASH"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(t, tt.input)
+		integer, ok := tt.expected.(int)
+		if ok {
+			testIntegerObject(t, evaluated, int64(integer))
+		} else {
+			testErrorObject(t, evaluated, tt.expected.(string))
+		}
+	}
+}
+
+func TestWithExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"with (x = 5) { x * 2 };", 10},
+		{"with (x = 5, y = x + 1) { x * y };", 30},
+		{"const x = 1; with (x = 5) { x } + x;", 6},
+		{"const a = with (x = 5) { x * 2 }; a;", 10},
+		{"with (x = 5) { x * 2 }; x;", "unknown identifier: x"},
+		{"with (x = 5, x = 6) { x };", `redeclared constant: "x" in one block`},
+		{"with (x = y) { x };", "unknown identifier: y"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(t, tt.input)
+		integer, ok := tt.expected.(int)
+		if ok {
+			testIntegerObject(t, evaluated, int64(integer))
+		} else {
+			testErrorObject(t, evaluated, tt.expected.(string))
+		}
+	}
+}
+
+func TestDoExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"const compute = fun() { 21 }; const x = do { const t = compute(); t * 2 }; x;", 42},
+		{"const t = 1; const x = do { const t = 5; t + 1 }; x + t;", 7},
+		{"do { 1; 2 } + 3;", 5},
+		{"do { const t = 5; }; t;", "unknown identifier: t"},
+		{"const f = fun() { const x = do { return 3; }; 4 }; f();", 3},
+		{"do { 1 + true };", "type mismatch: INTEGER + BOOLEAN"},
 	}
 
 	for _, tt := range tests {
@@ -677,6 +2403,7 @@ func TestHashIndexExpressions(t *testing.T) {
 			testErrorObject(t, evaluated, tt.expected.(string))
 		}
 	}
+	testNullObject(t, testEval(t, "do {};"))
 }
 
 func TestVoidFunction(t *testing.T) {
@@ -742,3 +2469,31 @@ func TestBlockScope(t *testing.T) {
 	}
 
 }
+
+func TestMethodCallChaining(t *testing.T) {
+	input := `
+	const isEven = fun(x) {
+		return x - x / 2 * 2 == 0;
+	};
+	const double = fun(x) {
+		return x * 2;
+	};
+
+	[1, 2, 3, 4].filter(isEven).map(double);
+	`
+
+	evaluated := testEval(t, input)
+	array, ok := evaluated.(*object.Array)
+	if !ok {
+		t.Fatalf("object is not Array. got=%T (%+v)", evaluated, evaluated)
+	}
+
+	expected := []int64{4, 8}
+	if len(array.Elements) != len(expected) {
+		t.Fatalf("array has wrong number of elements, expected=%d, got=%d", len(expected), len(array.Elements))
+	}
+
+	for i, el := range expected {
+		testIntegerObject(t, array.Elements[i], el)
+	}
+}
@@ -0,0 +1,178 @@
+package evaluator
+
+import (
+	"github.com/radlinskii/interpreter/ast"
+	"github.com/radlinskii/interpreter/object"
+	"github.com/radlinskii/interpreter/token"
+)
+
+// built-in functions that pure functions are not allowed to call
+var impureBuiltins = []string{"print", "puts", "write", "readFile", "readFileBytes", "writeFile", "readInt", "readFloat",
+	"spawn", "wait", "channel", "send", "recv", "objectCount"}
+
+// folder evaluates calls to pure functions with literal arguments before the program runs.
+type folder struct {
+	env  *object.Environment
+	pure map[string]bool
+}
+
+// Fold replaces calls to top-level pure functions made with literal arguments with their results.
+// The calls are evaluated with the settings of the environment the program is going to be evaluated in,
+// i.e. its context, iteration budget and arithmetic modes, but without its bindings.
+// If such a call fails, e.g. because the function turned out to be impure, it's left to be evaluated at runtime.
+func Fold(program *ast.Program, env *object.Environment) *ast.Program {
+	f := &folder{env: foldingEnvironment(env), pure: make(map[string]bool)}
+
+	for _, name := range impureBuiltins {
+		name := name
+		f.env.Set(name, &object.Builtin{Fn: func(args ...object.Object) object.Object {
+			return newError("impure built-in function: %s", name)
+		}})
+	}
+
+	for _, stmnt := range program.Statements {
+		f.foldStatement(stmnt)
+	}
+
+	return program
+}
+
+// foldingEnvironment returns an empty environment with the settings of given one.
+func foldingEnvironment(env *object.Environment) *object.Environment {
+	folding := object.NewEnvironment()
+	folding.SetContext(env.Context())
+	folding.SetIterationBudget(env.IterationBudget())
+	folding.SetTrueDivision(env.TrueDivision())
+	folding.SetBooleanArithmetic(env.BooleanArithmetic())
+
+	return folding
+}
+
+func (f *folder) foldStatement(stmnt ast.Statement) {
+	switch stmnt := stmnt.(type) {
+	case *ast.ConstGroupStatement:
+		for _, cs := range stmnt.Declarations {
+			f.foldStatement(cs)
+		}
+	case *ast.ConstStatement:
+		stmnt.Value = f.foldExpression(stmnt.Value)
+
+		if fl, ok := stmnt.Value.(*ast.FunctionLiteral); ok && fl.Pure {
+			if !isError(eval(stmnt, f.env)) {
+				f.pure[stmnt.Name.Value] = true
+			}
+		}
+	case *ast.ExpressionStatement:
+		stmnt.Expression = f.foldExpression(stmnt.Expression)
+	case *ast.ReturnStatement:
+		stmnt.ReturnValue = f.foldExpression(stmnt.ReturnValue)
+	}
+}
+
+// foldExpression folds given expression and its operands.
+// Bodies of blocks and functions are skipped as their bindings may shadow pure functions.
+func (f *folder) foldExpression(exp ast.Expression) ast.Expression {
+	switch exp := exp.(type) {
+	case *ast.PrefixExpression:
+		exp.Right = f.foldExpression(exp.Right)
+	case *ast.InfixExpression:
+		exp.Left = f.foldExpression(exp.Left)
+		if exp.Operator == "&&" || exp.Operator == "||" {
+			// the right operand is evaluated at runtime only if the left one doesn't short-circuit
+			left, ok := exp.Left.(*ast.BooleanLiteral)
+			if !ok || left.Value != (exp.Operator == "&&") {
+				break
+			}
+		}
+		exp.Right = f.foldExpression(exp.Right)
+	case *ast.IndexExpression:
+		exp.Left = f.foldExpression(exp.Left)
+		exp.Right = f.foldExpression(exp.Right)
+	case *ast.ConditionalExpression:
+		// only the branch selected by a literal condition would be evaluated at runtime
+		exp.Condition = f.foldExpression(exp.Condition)
+		if cond, ok := exp.Condition.(*ast.BooleanLiteral); ok {
+			if cond.Value {
+				exp.Consequence = f.foldExpression(exp.Consequence)
+			} else {
+				exp.Alternative = f.foldExpression(exp.Alternative)
+			}
+		}
+	case *ast.ArrayLiteral:
+		f.foldExpressions(exp.Elements)
+	case *ast.TemplateLiteral:
+		f.foldExpressions(exp.Parts)
+	case *ast.PropertyExpression:
+		exp.Receiver = f.foldExpression(exp.Receiver)
+	case *ast.MethodCallExpression:
+		exp.Receiver = f.foldExpression(exp.Receiver)
+		f.foldExpressions(exp.Arguments)
+	case *ast.CallExpression:
+		exp.Function = f.foldExpression(exp.Function)
+		f.foldExpressions(exp.Arguments)
+
+		return f.foldCall(exp)
+	}
+
+	return exp
+}
+
+func (f *folder) foldExpressions(exps []ast.Expression) {
+	for i, e := range exps {
+		exps[i] = f.foldExpression(e)
+	}
+}
+
+// foldCall evaluates the call if it's a call to pure function with literal arguments.
+func (f *folder) foldCall(call *ast.CallExpression) ast.Expression {
+	ident, ok := call.Function.(*ast.Identifier)
+	if !ok || !f.pure[ident.Value] {
+		return call
+	}
+
+	for _, arg := range call.Arguments {
+		if !isLiteral(arg) {
+			return call
+		}
+	}
+
+	result := eval(call, f.env)
+	if lit := objectToLiteral(result, call); lit != nil {
+		return lit
+	}
+
+	return call
+}
+
+func isLiteral(exp ast.Expression) bool {
+	switch exp.(type) {
+	case *ast.IntegerLiteral, *ast.FloatLiteral, *ast.StringLiteral, *ast.BooleanLiteral:
+		return true
+	default:
+		return false
+	}
+}
+
+// objectToLiteral creates literal node representing given primitive object,
+// returns nil for objects that can't be represented by a literal.
+func objectToLiteral(obj object.Object, call *ast.CallExpression) ast.Expression {
+	tok := call.Token
+	tok.Literal = obj.Inspect()
+
+	switch obj := obj.(type) {
+	case *object.Integer:
+		tok.Type = token.INT
+		return &ast.IntegerLiteral{Token: tok, Value: obj.Value}
+	case *object.Float:
+		tok.Type = token.FLOAT
+		return &ast.FloatLiteral{Token: tok, Value: obj.Value}
+	case *object.String:
+		tok.Type = token.STRING
+		return &ast.StringLiteral{Token: tok, Value: obj.Value}
+	case *object.Boolean:
+		tok.Type = token.BOOLEAN
+		return &ast.BooleanLiteral{Token: tok, Value: obj.Value}
+	default:
+		return nil
+	}
+}
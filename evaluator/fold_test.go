@@ -0,0 +1,134 @@
+package evaluator
+
+import (
+	"context"
+	"testing"
+
+	"github.com/radlinskii/interpreter/lexer"
+	"github.com/radlinskii/interpreter/object"
+	"github.com/radlinskii/interpreter/parser"
+)
+
+func TestFoldPureFunctionCalls(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{
+			"const sq = pure fun(x) { x * x }; sq(4);",
+			"const sq = pure fun(x)(x * x);16",
+		},
+		{
+			"const sq = pure fun(x) { x * x }; const a = sq(3) + sq(2);",
+			"const sq = pure fun(x)(x * x);const a = (9 + 4);",
+		},
+		{
+			`const greet = pure fun(name) { "hi " + name }; greet("bob");`,
+			`const greet = pure fun(name)(hi  + name);hi bob`,
+		},
+		{
+			"const sq = pure fun(x) { x * x }; sq(sq(2));",
+			"const sq = pure fun(x)(x * x);16",
+		},
+		{
+			"const sq = pure fun(x) { x * x }; const y = 2; sq(y);",
+			"const sq = pure fun(x)(x * x);const y = 2;sq(y)",
+		},
+		{
+			"const sq = fun(x) { x * x }; sq(4);",
+			"const sq = fun(x)(x * x);sq(4)",
+		},
+		{
+			"const loud = pure fun(x) { print(x); x }; loud(4);",
+			"const loud = pure fun(x)print(x)x;loud(4)",
+		},
+		{
+			"const sq = pure fun(x) { x * x }; const r = true ? sq(2) : sq(3);",
+			"const sq = pure fun(x)(x * x);const r = (true ? 4 : sq(3));",
+		},
+		{
+			"const sq = pure fun(x) { x * x }; const y = true; const r = y ? sq(2) : sq(3);",
+			"const sq = pure fun(x)(x * x);const y = true;const r = (y ? sq(2) : sq(3));",
+		},
+		{
+			"const loop = pure fun(n) { while (true) { 1; } n }; const r = false ? loop(1) : 0;",
+			"const loop = pure fun(n)whiletrue 1n;const r = (false ? loop(1) : 0);",
+		},
+		{
+			"const loop = pure fun(n) { while (true) { 1; } n }; const r = false and loop(1);",
+			"const loop = pure fun(n)whiletrue 1n;const r = (false && loop(1));",
+		},
+		{
+			"const loop = pure fun(n) { while (true) { 1; } n }; const r = true || loop(1);",
+			"const loop = pure fun(n)whiletrue 1n;const r = (true || loop(1));",
+		},
+		{
+			"const even = pure fun(x) { x % 2 == 0 }; const r = true and even(4);",
+			"const even = pure fun(x)((x % 2) == 0);const r = (true && true);",
+		},
+		{
+			"const even = pure fun(x) { x % 2 == 0 }; const y = true; const r = y or even(4);",
+			"const even = pure fun(x)((x % 2) == 0);const y = true;const r = (y || even(4));",
+		},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := parser.New(l)
+		program := Fold(p.ParseProgram(), object.NewEnvironment())
+
+		if program.String() != tt.expected {
+			t.Errorf("wrong folded program. expected=%q, got=%q", tt.expected, program.String())
+		}
+	}
+}
+
+func TestEvalFoldedProgram(t *testing.T) {
+	input := "const sq = pure fun(x) { x * x }; const y = 3; sq(4) + sq(y);"
+
+	l := lexer.New(input)
+	p := parser.New(l)
+	env := object.NewEnvironment()
+	program := Fold(p.ParseProgram(), env)
+
+	testIntegerObject(t, evalProgram(program, env), 25)
+}
+
+func TestFoldWithSettingsOfEnvironment(t *testing.T) {
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	tests := []struct {
+		input    string
+		setup    func(env *object.Environment) *object.Environment
+		expected string
+	}{
+		{
+			"const inc = pure fun(x) { x + 1 }; inc(true);",
+			func(env *object.Environment) *object.Environment { env.SetBooleanArithmetic(true); return env },
+			"const inc = pure fun(x)(x + 1);2",
+		},
+		{
+			"const f = pure fun() { while (true) { 1; } }; f();",
+			func(env *object.Environment) *object.Environment { return env.WithContext(cancelled) },
+			"const f = pure fun()whiletrue 1;f()",
+		},
+		{
+			"const spin = pure fun(n) { for (i in 0..n) { 1; } n }; const r = false ? spin(90) : spin(5);",
+			func(env *object.Environment) *object.Environment {
+				env.SetIterationBudget(&object.IterationBudget{Max: 100})
+				return env
+			},
+			"const spin = pure fun(n)for(i in (0..n)) 1n;const r = (false ? spin(90) : 5);",
+		},
+	}
+
+	for _, tt := range tests {
+		program := parser.New(lexer.New(tt.input)).ParseProgram()
+		program = Fold(program, tt.setup(object.NewEnvironment()))
+
+		if program.String() != tt.expected {
+			t.Errorf("wrong folded program. expected=%q, got=%q", tt.expected, program.String())
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package evaluator
+
+import (
+	"runtime"
+
+	"github.com/radlinskii/interpreter/ast"
+	"github.com/radlinskii/interpreter/object"
+)
+
+// newGenerator starts the generator function in a goroutine which waits to be resumed by the `next` built-in.
+// Generators that are never exhausted stop their goroutines once the context of the call is done
+// or once they become unreachable.
+func newGenerator(fun *object.Function, args []object.Object, caller *object.Environment) *object.Generator {
+	co := &object.Coroutine{Resume: make(chan struct{}), Yields: make(chan object.Object), Stop: make(chan struct{})}
+	env := object.NewGeneratorEnvironment(extendedFunctionEnv(fun, args, caller), co)
+	done := cancellation(env)
+
+	go func() {
+		defer close(co.Yields)
+
+		select {
+		case <-co.Resume:
+		case <-co.Stop:
+			return
+		case <-done:
+			return
+		}
+
+		result := evalBlockStatement(fun.Body, env)
+		if err, ok := result.(*object.Error); ok {
+			select {
+			case co.Yields <- withStackFrame(err, fun):
+			case <-co.Stop:
+			case <-done:
+			}
+		}
+	}()
+
+	gen := &object.Generator{Coroutine: co}
+	// the goroutine refers only to the coroutine, so the generator can become unreachable while it's suspended
+	runtime.SetFinalizer(gen, func(gen *object.Generator) { close(gen.Stop) })
+
+	return gen
+}
+
+// evalYieldStatement passes the value to the caller of `next` and suspends the generator until it's resumed.
+func evalYieldStatement(ys *ast.YieldStatement, env *object.Environment) object.Object {
+	co := env.Coroutine()
+	if co == nil {
+		return newError("yield statement not permitted outside generator function")
+	}
+
+	val := eval(ys.Value, env)
+	if isError(val) {
+		return val
+	}
+
+	done := cancellation(env)
+
+	select {
+	case co.Yields <- val:
+	case <-co.Stop:
+		return newError("generator stopped")
+	case <-done:
+		return newError("evaluation cancelled")
+	}
+
+	select {
+	case <-co.Resume:
+	case <-co.Stop:
+		return newError("generator stopped")
+	case <-done:
+		return newError("evaluation cancelled")
+	}
+
+	return nil
+}
+
+// resumeGenerator returns the next value yielded by the generator, or NULL if it has finished.
+func resumeGenerator(gen *object.Generator, env *object.Environment) object.Object {
+	if gen.Done {
+		return NULL
+	}
+
+	done := cancellation(env)
+
+	select {
+	case gen.Resume <- struct{}{}:
+	case <-gen.Yields:
+		// the generator can only finish before being resumed when its context is done
+		gen.Done = true
+		return NULL
+	case <-done:
+		return newError("evaluation cancelled")
+	}
+
+	select {
+	case val, ok := <-gen.Yields:
+		if !ok {
+			gen.Done = true
+			return NULL
+		}
+		if isError(val) {
+			gen.Done = true
+		}
+
+		return val
+	case <-done:
+		return newError("evaluation cancelled")
+	}
+}
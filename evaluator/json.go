@@ -0,0 +1,95 @@
+package evaluator
+
+import (
+	"bytes"
+	"encoding/json"
+	"math"
+	"sort"
+	"strconv"
+
+	"github.com/radlinskii/interpreter/object"
+)
+
+// jsonStringify returns JSON representation of the object.
+// Hash keys are converted to strings and sorted, so the output doesn't depend on the order of insertion.
+func jsonStringify(obj object.Object) (string, *object.Error) {
+	var out bytes.Buffer
+
+	if err := writeJSON(&out, obj); err != nil {
+		return "", err
+	}
+
+	return out.String(), nil
+}
+
+func writeJSON(out *bytes.Buffer, obj object.Object) *object.Error {
+	switch obj := obj.(type) {
+	case *object.Integer:
+		out.WriteString(strconv.FormatInt(obj.Value, 10))
+	case *object.Float:
+		if math.IsInf(obj.Value, 0) || math.IsNaN(obj.Value) {
+			return newError("value %s can't be converted to JSON", obj.Inspect())
+		}
+		out.WriteString(strconv.FormatFloat(obj.Value, 'g', -1, 64))
+	case *object.Boolean:
+		out.WriteString(strconv.FormatBool(obj.Value))
+	case *object.Null:
+		out.WriteString("null")
+	case *object.String:
+		writeJSONString(out, obj.Value)
+	case *object.Array:
+		out.WriteString("[")
+		for i, el := range obj.Elements {
+			if i > 0 {
+				out.WriteString(", ")
+			}
+			if err := writeJSON(out, el); err != nil {
+				return err
+			}
+		}
+		out.WriteString("]")
+	case *object.Hash:
+		values := make(map[string]object.Object, len(obj.Pairs))
+		keys := []string{}
+		for _, pair := range obj.Pairs {
+			key := pair.Key.Inspect()
+			if _, ok := values[key]; ok {
+				return newError("keys of different types convert to the same JSON key: %q", key)
+			}
+			values[key] = pair.Value
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		return writeJSONObject(out, keys, values)
+	case *object.Record:
+		return writeJSONObject(out, obj.FieldNames, obj.Fields)
+	default:
+		return newError("value of type %s can't be converted to JSON", obj.Type())
+	}
+
+	return nil
+}
+
+func writeJSONObject(out *bytes.Buffer, keys []string, values map[string]object.Object) *object.Error {
+	out.WriteString("{")
+	for i, key := range keys {
+		if i > 0 {
+			out.WriteString(", ")
+		}
+		writeJSONString(out, key)
+		out.WriteString(": ")
+		if err := writeJSON(out, values[key]); err != nil {
+			return err
+		}
+	}
+	out.WriteString("}")
+
+	return nil
+}
+
+func writeJSONString(out *bytes.Buffer, s string) {
+	// marshaling a string can't fail
+	encoded, _ := json.Marshal(s)
+	out.Write(encoded)
+}
@@ -0,0 +1,85 @@
+package evaluator
+
+import (
+	"github.com/radlinskii/interpreter/object"
+)
+
+// spawn calls the function in a new goroutine scheduled with the other functions of the program.
+// Functions get a copy of their environment, so they only can communicate with the caller through channels.
+func spawn(fun object.Object, env *object.Environment) *object.Task {
+	if function, ok := fun.(*object.Function); ok {
+		isolated := *function
+		isolated.Env = function.Env.Isolate()
+		fun = &isolated
+	}
+
+	task := &object.Task{}
+	scheduler := env.Scheduler()
+
+	scheduler.Go(func() {
+		result := applyFunction(fun, []object.Object{}, env)
+
+		scheduler.Lock()
+		task.Result = result
+		task.Finished = true
+		scheduler.Unlock()
+	})
+
+	return task
+}
+
+// waitTask blocks until the spawned function finishes and returns its result.
+func waitTask(task *object.Task, env *object.Environment) object.Object {
+	scheduler := env.Scheduler()
+	scheduler.Lock()
+	defer scheduler.Unlock()
+
+	if err := scheduler.Wait(env.Context(), func() bool { return task.Finished }); err != nil {
+		return waitError(err)
+	}
+
+	return task.Result
+}
+
+// sendValue blocks until the value is received or buffered by the channel.
+func sendValue(ch *object.Channel, val object.Object, env *object.Environment) object.Object {
+	scheduler := env.Scheduler()
+	scheduler.Lock()
+	defer scheduler.Unlock()
+
+	msg := &object.Message{Value: val}
+	ch.Queue = append(ch.Queue, msg)
+
+	if err := scheduler.Wait(env.Context(), func() bool { return ch.Buffered(msg) }); err != nil {
+		ch.Remove(msg)
+		return waitError(err)
+	}
+
+	return NULL
+}
+
+// receiveValue blocks until a value is sent to the channel and returns it.
+func receiveValue(ch *object.Channel, env *object.Environment) object.Object {
+	scheduler := env.Scheduler()
+	scheduler.Lock()
+	defer scheduler.Unlock()
+
+	if err := scheduler.Wait(env.Context(), func() bool { return len(ch.Queue) > 0 }); err != nil {
+		return waitError(err)
+	}
+
+	msg := ch.Queue[0]
+	msg.Received = true
+	ch.Queue = ch.Queue[1:]
+
+	return msg.Value
+}
+
+// waitError converts error of waiting for other functions to an error of the program.
+func waitError(err error) *object.Error {
+	if err == object.ErrDeadlock {
+		return newError("%s", err)
+	}
+
+	return newError("evaluation cancelled")
+}
@@ -0,0 +1,138 @@
+// Package interpreter lets Go programs embed Junior.
+package interpreter
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+
+	"github.com/radlinskii/interpreter/ast"
+	"github.com/radlinskii/interpreter/evaluator"
+	"github.com/radlinskii/interpreter/lexer"
+	"github.com/radlinskii/interpreter/object"
+	"github.com/radlinskii/interpreter/parser"
+)
+
+// Interpreter evaluates programs in one global environment, so later programs see constants of the former ones.
+type Interpreter struct {
+	env      *object.Environment
+	warnings *object.Warnings
+	last     []string // warnings of the last run program
+}
+
+// Option configures the Interpreter created with New.
+type Option func(*Interpreter) error
+
+// New creates new Interpreter configured with given options.
+func New(options ...Option) (*Interpreter, error) {
+	in := &Interpreter{env: object.NewEnvironment(), warnings: &object.Warnings{}}
+	in.env.SetWarnings(in.warnings)
+
+	for _, option := range options {
+		if err := option(in); err != nil {
+			return nil, err
+		}
+	}
+
+	return in, nil
+}
+
+// WithPrelude evaluates given program, e.g. defining common helper functions, before any program is run.
+// Errors of the prelude make New fail, its output is discarded.
+func WithPrelude(src string) Option {
+	return func(in *Interpreter) error {
+		program, err := parse(src)
+		if err != nil {
+			return errors.New("prelude: " + err.Error())
+		}
+
+		evaluated := evaluator.Eval(program, in.env)
+		in.env.Output().Drain()
+
+		if err, ok := evaluated.(*object.Error); ok {
+			return errors.New("prelude: " + strings.TrimSpace(err.Inspect()))
+		}
+
+		return nil
+	}
+}
+
+// WithIterationBudget limits the total number of iterations of all loops evaluated by the Interpreter,
+// exceeding it stops evaluation with an error. Iterations of loops in the prelude and in folded calls of pure functions count as well,
+// and so does every element of a range turned into an array, e.g. len(0..10) spends 10 iterations.
+func WithIterationBudget(max int64) Option {
+	return func(in *Interpreter) error {
+		in.env.SetIterationBudget(&object.IterationBudget{Max: max})
+
+		return nil
+	}
+}
+
+// WithTrueDivision makes `/` between integers result in a float when they aren't evenly divisible, e.g. 7 / 2 is 3.5.
+// The `div` operator can be used for floor division.
+func WithTrueDivision() Option {
+	return func(in *Interpreter) error {
+		in.env.SetTrueDivision(true)
+
+		return nil
+	}
+}
+
+// WithBooleanArithmetic lets booleans be used in arithmetic as integers, e.g. true + true is 2.
+// Without it such operations are errors.
+func WithBooleanArithmetic() Option {
+	return func(in *Interpreter) error {
+		in.env.SetBooleanArithmetic(true)
+
+		return nil
+	}
+}
+
+// WithInput makes `readInt` and `readFloat` read from r, e.g. the host's standard input.
+// Without it programs have no input and those built-ins return null.
+func WithInput(r io.Reader) Option {
+	return func(in *Interpreter) error {
+		in.env.SetInput(r)
+
+		return nil
+	}
+}
+
+// Run evaluates the program and returns its output, which includes evaluation errors.
+// Programs with syntax errors are not evaluated.
+func (in *Interpreter) Run(src string) (string, error) {
+	return in.RunContext(context.Background(), src)
+}
+
+// RunContext is like Run, but the evaluation stops with "evaluation cancelled" error once the context is done,
+// e.g. to limit time untrusted programs can take. Cancellation is checked on loop iterations, elements of created ranges and function calls.
+func (in *Interpreter) RunContext(ctx context.Context, src string) (string, error) {
+	program, err := parse(src)
+	if err != nil {
+		return "", err
+	}
+
+	in.warnings.Drain()
+	output := evaluator.EvalProgram(program, in.env.WithContext(ctx))
+	in.last = in.warnings.Drain()
+
+	return output, nil
+}
+
+// Warnings returns warnings about suspicious code found while running the last program,
+// e.g. constants shadowing constants of outer scopes.
+func (in *Interpreter) Warnings() []string {
+	return in.last
+}
+
+func parse(src string) (*ast.Program, error) {
+	p := parser.New(lexer.New(src))
+	program := p.ParseProgram()
+
+	if len(p.Errors()) > 0 {
+		return nil, errors.New(strings.Join(p.Errors(), "\n"))
+	}
+
+	return program, nil
+}
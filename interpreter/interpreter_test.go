@@ -0,0 +1,357 @@
+package interpreter
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestPrelude(t *testing.T) {
+	in, err := New(WithPrelude("const double = fun(x) { x * 2 };"))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	output, err := in.Run("double(21);")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if output != "42" {
+		t.Errorf("wrong output. expected=%q, got=%q", "42", output)
+	}
+}
+
+func TestPreludeOutputIsDiscarded(t *testing.T) {
+	in, err := New(WithPrelude(`print("from prelude");`))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	output, err := in.Run("2;")
+	if err != nil || output != "2" {
+		t.Errorf("expected output of the prelude to be discarded. got=%q, %v", output, err)
+	}
+}
+
+func TestSeparateOutputs(t *testing.T) {
+	const runs = 20
+
+	var wg sync.WaitGroup
+	outputs := make([]string, runs)
+
+	for i := 0; i < runs; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			in, err := New()
+			if err != nil {
+				t.Errorf("unexpected error: %s", err)
+				return
+			}
+			outputs[i], _ = in.Run(fmt.Sprintf("for (j in 0..50) { print(%d); }", i))
+		}(i)
+	}
+	wg.Wait()
+
+	for i, output := range outputs {
+		expected := strings.Repeat(fmt.Sprintf("%d \n", i), 50) + "null"
+		if output != expected {
+			t.Errorf("interpreters printed to the same output. expected=%q, got=%q", expected, output)
+		}
+	}
+}
+
+func TestPreludeErrors(t *testing.T) {
+	tests := []struct {
+		prelude       string
+		expectedError string
+	}{
+		{"const a = 1", "prelude: expected semicolon at line: 1"},
+		{"const a = b;", "prelude: ERROR: line 1: unknown identifier: b"},
+	}
+
+	for _, tt := range tests {
+		in, err := New(WithPrelude(tt.prelude))
+		if in != nil || err == nil {
+			t.Fatalf("expected prelude %q to fail", tt.prelude)
+		}
+		if err.Error() != tt.expectedError {
+			t.Errorf("wrong error. expected=%q, got=%q", tt.expectedError, err.Error())
+		}
+	}
+}
+
+func TestIterationBudget(t *testing.T) {
+	in, err := New(WithIterationBudget(1000))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	output, err := in.Run("while (true) { 1; }")
+	if err != nil || !strings.Contains(output, "ERROR: iteration budget exceeded") {
+		t.Errorf("expected infinite loop to be stopped. got=%q, %v", output, err)
+	}
+
+	in, err = New(WithIterationBudget(1000))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+
+		output, err = in.Run("const f = pure fun() { while (true) { 1; } }; f();")
+	}()
+
+	select {
+	case <-done:
+		if err != nil || !strings.Contains(output, "ERROR: iteration budget exceeded") {
+			t.Errorf("expected infinite loop of pure function to be stopped. got=%q, %v", output, err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("infinite loop of pure function wasn't stopped by the budget")
+	}
+}
+
+func TestRunContextTimeout(t *testing.T) {
+	in, err := New()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	output, err := in.RunContext(ctx, "while (true) { 1; }")
+	if err != nil || !strings.Contains(output, "ERROR: evaluation cancelled") {
+		t.Errorf("expected infinite loop to be cancelled. got=%q, %v", output, err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("expected loop to be cancelled in time. took %s", elapsed)
+	}
+
+	ctx, cancel = context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start = time.Now()
+	output, err = in.RunContext(ctx, "len(0..10000000000);")
+	if err != nil || !strings.Contains(output, "ERROR: evaluation cancelled") {
+		t.Errorf("expected creating huge range to be cancelled. got=%q, %v", output, err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("expected range to be cancelled in time. took %s", elapsed)
+	}
+
+	output, err = in.Run("1 + 1;")
+	if err != nil || output != "2" {
+		t.Errorf("expected context not to affect later programs. got=%q, %v", output, err)
+	}
+}
+
+func TestRunContextCancelled(t *testing.T) {
+	in, err := New(WithPrelude("const f = fun(x) { x };"))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	output, err := in.RunContext(ctx, "f(1);")
+	if err != nil || !strings.Contains(output, "ERROR: evaluation cancelled") {
+		t.Errorf("expected function call to be cancelled. got=%q, %v", output, err)
+	}
+}
+
+func TestRunContextOfFunctions(t *testing.T) {
+	in, err := New()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	if _, err := in.RunContext(ctx, "const double = fun(x) { x * 2 };"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	cancel()
+
+	output, err := in.Run("[double(1), map([2], double)];")
+	if err != nil || output != "[2, [4]]" {
+		t.Errorf("expected functions to run in the context of their callers. got=%q, %v", output, err)
+	}
+
+	output, err = in.RunContext(ctx, "map([2], double);")
+	if err != nil || !strings.Contains(output, "ERROR: evaluation cancelled") {
+		t.Errorf("expected callbacks of built-ins to be cancelled. got=%q, %v", output, err)
+	}
+
+	ctx, cancel = context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	output, err = in.RunContext(ctx, "wait(spawn(fun() { while (true) { 1; } }));")
+	if err != nil || !strings.Contains(output, "ERROR: evaluation cancelled") {
+		t.Errorf("expected spawned function to be cancelled. got=%q, %v", output, err)
+	}
+}
+
+func TestRunContextWaitingForChannels(t *testing.T) {
+	in, err := New()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	output, err := in.RunContext(ctx, "const ch = channel(); spawn(fun() { while (true) { 1; } }); recv(ch);")
+	if err != nil || !strings.Contains(output, "ERROR: evaluation cancelled") {
+		t.Errorf("expected waiting for the channel to be cancelled. got=%q, %v", output, err)
+	}
+	if elapsed := time.Since(start); elapsed > 3*time.Second {
+		t.Errorf("expected waiting to be cancelled in time. took %s", elapsed)
+	}
+
+	output, err = in.Run("recv(channel());")
+	if err != nil || !strings.Contains(output, "ERROR: deadlock") {
+		t.Errorf("expected receiving from channel nothing is sent to to fail. got=%q, %v", output, err)
+	}
+}
+
+func TestTrueDivision(t *testing.T) {
+	in, err := New(WithTrueDivision())
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	output, err := in.Run("[7 / 2, 8 / 2, 7 div 2];")
+	if err != nil || output != "[3.5, 4, 3]" {
+		t.Errorf("expected true division. got=%q, %v", output, err)
+	}
+
+	output, err = in.Run("const half = pure fun(x) { x / 2 }; half(5);")
+	if err != nil || output != "2.5" {
+		t.Errorf("expected true division in folded call. got=%q, %v", output, err)
+	}
+}
+
+func TestInput(t *testing.T) {
+	in, err := New(WithInput(strings.NewReader("1\n2.5\n3\n")))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	output, err := in.Run("readInt() + readFloat();")
+	if err != nil || output != "3.5" {
+		t.Errorf("expected input to be read. got=%q, %v", output, err)
+	}
+
+	output, err = in.Run("[readInt(), readInt()];")
+	if err != nil || output != "[3, null]" {
+		t.Errorf("expected later runs to keep reading the input. got=%q, %v", output, err)
+	}
+
+	in, err = New()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	output, err = in.Run("readInt();")
+	if err != nil || output != "null" {
+		t.Errorf("expected no input by default. got=%q, %v", output, err)
+	}
+}
+
+func TestBooleanArithmetic(t *testing.T) {
+	in, err := New(WithBooleanArithmetic())
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	output, err := in.Run("[true + true, 3 * false];")
+	if err != nil || output != "[2, 0]" {
+		t.Errorf("expected booleans to be used as integers. got=%q, %v", output, err)
+	}
+
+	in, err = New()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	output, err = in.Run("true + true;")
+	if err != nil || !strings.Contains(output, "unknown operator: BOOLEAN + BOOLEAN") {
+		t.Errorf("expected booleans not to be used as integers by default. got=%q, %v", output, err)
+	}
+}
+
+func TestWarnings(t *testing.T) {
+	in, err := New()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	output, err := in.Run("const x = 1;\nconst f = fun() {\n\tconst x = 2;\n\tx;\n};\nf();")
+	if err != nil || output != "2" {
+		t.Errorf("expected shadowing declaration to be evaluated. got=%q, %v", output, err)
+	}
+
+	warnings := in.Warnings()
+	expected := `line 3: constant "x" shadows constant of an outer scope`
+	if len(warnings) != 1 || warnings[0] != expected {
+		t.Errorf("wrong warnings. expected=%q, got=%q", expected, warnings)
+	}
+
+	if _, err := in.Run("x;"); err != nil || len(in.Warnings()) != 0 {
+		t.Errorf("expected warnings to be cleared by the next run. got=%q, %v", in.Warnings(), err)
+	}
+}
+
+func TestWarningsOfLoops(t *testing.T) {
+	in, err := New()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	_, err = in.Run("const x = 1;\nconst f = fun() { const x = 2; x };\nfor (i in 1..1000) { f(); }\nfor (i in 1..10) { f(); }")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	warnings := in.Warnings()
+	expected := `line 2: constant "x" shadows constant of an outer scope`
+	if len(warnings) != 1 || warnings[0] != expected {
+		t.Errorf("expected warning to be reported once. got=%d warnings (%q)", len(warnings), warnings)
+	}
+}
+
+func TestRun(t *testing.T) {
+	in, err := New()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if _, err := in.Run("const a = 5;"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	output, err := in.Run("a + 1;")
+	if err != nil || output != "6" {
+		t.Errorf("expected constants to be shared between programs. got=%q, %v", output, err)
+	}
+
+	output, err = in.Run("const b = 1;\n\nconst c = (a + b;\nc;")
+	if err == nil || err.Error() != `unexpected token: ";" (expected: ")") at line: 3` {
+		t.Errorf("expected syntax error with line number. got=%q, %v", output, err)
+	}
+
+	output, err = in.Run("a +;")
+	if err == nil || !strings.Contains(err.Error(), "unexpected token") {
+		t.Errorf("expected syntax error. got=%q, %v", output, err)
+	}
+}
@@ -1,10 +1,26 @@
 package lexer
 
 import (
+	"bytes"
 	"fmt"
+	"strings"
+
 	"github.com/radlinskii/interpreter/token"
 )
 
+// defaultTabWidth is the number of columns a tab advances by default.
+const defaultTabWidth = 1
+
+// CommentSyntax describes delimiters of comments, an empty delimiter disables given kind of comments.
+type CommentSyntax struct {
+	Line       string
+	BlockStart string
+	BlockEnd   string
+}
+
+// DefaultCommentSyntax is the comment syntax of Junior.
+var DefaultCommentSyntax = CommentSyntax{Line: "//", BlockStart: "/*", BlockEnd: "*/"}
+
 // Lexer is a struct representing the lexical analyzer.
 type Lexer struct {
 	input        string
@@ -12,18 +28,57 @@ type Lexer struct {
 	nextPosition int
 	ch           byte
 	RowNum       int
+	ColNum       int // column of the current character
+	tabWidth     int
+	strict       bool
+	comments     CommentSyntax
 }
 
 // New creates new instance of the Lexer.
 func New(input string) *Lexer {
-	l := &Lexer{input: input, RowNum: 1}
+	l := &Lexer{input: input, RowNum: 1, tabWidth: defaultTabWidth, comments: DefaultCommentSyntax}
 	l.readChar()
+	l.skipShebang()
 	return l
 }
 
+// NewStrict creates new instance of the Lexer which rejects suspicious input,
+// e.g. numbers immediately followed by letters like "123abc".
+func NewStrict(input string) *Lexer {
+	l := New(input)
+	l.strict = true
+	return l
+}
+
+// NewWithComments creates new instance of the Lexer recognizing comments with given delimiters,
+// e.g. "#" for line comments when embedding Junior in a DSL.
+func NewWithComments(input string, comments CommentSyntax) *Lexer {
+	l := New(input)
+	l.comments = comments
+	return l
+}
+
+// SetTabWidth sets the number of columns between tab stops used to compute ColNum,
+// e.g. 8 to match editors displaying tabs 8 columns wide.
+func (l *Lexer) SetTabWidth(width int) {
+	if width > 0 {
+		l.tabWidth = width
+	}
+}
+
+// Skips the "#!" line if it's the very first line of the input,
+// so scripts can be made executable.
+func (l *Lexer) skipShebang() {
+	if l.ch == '#' && l.peekChar() == '!' {
+		l.skipOneLineComment()
+	}
+}
+
 // Reads next char from the input.
 // Increments values of position and nextPositon and advances the current character.
 func (l *Lexer) readChar() {
+	l.advanceColumn()
+
 	if l.nextPosition >= len(l.input) {
 		l.ch = 0
 	} else {
@@ -33,6 +88,19 @@ func (l *Lexer) readChar() {
 	l.nextPosition++
 }
 
+// Updates ColNum for the character following the current one.
+// Tabs move the column to the next tab stop.
+func (l *Lexer) advanceColumn() {
+	switch {
+	case l.nextPosition == 0 || l.ch == '\n':
+		l.ColNum = 1
+	case l.ch == '\t':
+		l.ColNum = (l.ColNum-1)/l.tabWidth*l.tabWidth + l.tabWidth + 1
+	default:
+		l.ColNum++
+	}
+}
+
 // Returns next character from the input.
 func (l *Lexer) peekChar() byte {
 	if l.nextPosition >= len(l.input) {
@@ -56,18 +124,26 @@ func (l *Lexer) skipOneLineComment() {
 	}
 }
 
+// Checks if the input at current position starts with given delimiter.
+func (l *Lexer) startsWith(delimiter string) bool {
+	return delimiter != "" && l.position < len(l.input) && strings.HasPrefix(l.input[l.position:], delimiter)
+}
+
+// Skips n characters of the input.
+func (l *Lexer) skip(n int) {
+	for i := 0; i < n; i++ {
+		l.readChar()
+	}
+}
+
 func (l *Lexer) skipMultipleLineComment() token.Token {
-	// skipping '/*'
-	l.readChar()
-	l.readChar()
+	column := l.ColNum
+	l.skip(len(l.comments.BlockStart))
 
 	for l.ch != 0 {
-		if l.ch == '*' {
-			if l.peekChar() == '/' {
-				l.readChar()
-				l.readChar()
-				return l.NextToken()
-			}
+		if l.startsWith(l.comments.BlockEnd) {
+			l.skip(len(l.comments.BlockEnd))
+			return l.NextToken()
 		}
 
 		if l.ch == '\n' || l.ch == '\r' {
@@ -78,18 +154,37 @@ func (l *Lexer) skipMultipleLineComment() token.Token {
 
 	msg := fmt.Sprintf("FATAL ERROR: comment not terminated at line: %d\n\n", l.RowNum)
 
-	return token.Token{Type: token.ILLEGAL, Literal: msg, LineNumber: l.RowNum}
+	return token.Token{Type: token.ILLEGAL, Literal: msg, LineNumber: l.RowNum, Column: column}
 }
 
 // NextToken analyzes text and returns the first token it founds.
-func (l *Lexer) NextToken() (tok token.Token) {
+func (l *Lexer) NextToken() token.Token {
 	l.skipWhitespace()
 
+	if l.startsWith(l.comments.Line) {
+		l.skipOneLineComment()
+		return l.NextToken()
+	} else if l.startsWith(l.comments.BlockStart) {
+		return l.skipMultipleLineComment()
+	}
+
+	column := l.ColNum
+	tok := l.readToken()
+	tok.Column = column
+
+	return tok
+}
+
+// Reads the token starting at the current character.
+func (l *Lexer) readToken() (tok token.Token) {
 	switch l.ch {
 	case '=':
 		if l.peekChar() == '=' {
 			l.readChar()
 			tok = token.Token{Type: token.EQ, Literal: "==", LineNumber: l.RowNum}
+		} else if l.peekChar() == '>' {
+			l.readChar()
+			tok = token.Token{Type: token.ARROW, Literal: "=>", LineNumber: l.RowNum}
 		} else {
 			tok = newToken(token.ASSIGN, l.ch, l.RowNum)
 		}
@@ -106,13 +201,23 @@ func (l *Lexer) NextToken() (tok token.Token) {
 		}
 	case '*':
 		tok = newToken(token.ASTERISK, l.ch, l.RowNum)
-	case '/':
-		if l.peekChar() == '/' {
-			l.skipOneLineComment()
-			return l.NextToken()
-		} else if l.peekChar() == '*' {
-			return l.skipMultipleLineComment()
+	case '%':
+		tok = newToken(token.MODULO, l.ch, l.RowNum)
+	case '&':
+		if l.peekChar() == '&' {
+			l.readChar()
+			tok = token.Token{Type: token.AND, Literal: "&&", LineNumber: l.RowNum}
+		} else {
+			tok = l.illegalCharacter()
 		}
+	case '|':
+		if l.peekChar() == '|' {
+			l.readChar()
+			tok = token.Token{Type: token.OR, Literal: "||", LineNumber: l.RowNum}
+		} else {
+			tok = l.illegalCharacter()
+		}
+	case '/':
 		tok = newToken(token.SLASH, l.ch, l.RowNum)
 	case '<':
 		if l.peekChar() == '=' {
@@ -144,8 +249,30 @@ func (l *Lexer) NextToken() (tok token.Token) {
 		tok = newToken(token.LBRACKET, l.ch, l.RowNum)
 	case ']':
 		tok = newToken(token.RBRACKET, l.ch, l.RowNum)
+	case '?':
+		tok = newToken(token.QUESTION, l.ch, l.RowNum)
 	case ':':
 		tok = newToken(token.COLON, l.ch, l.RowNum)
+	case '.':
+		if l.peekChar() == '.' {
+			l.readChar()
+			if l.peekChar() == '=' {
+				l.readChar()
+				tok = token.Token{Type: token.RANGEINCL, Literal: "..=", LineNumber: l.RowNum}
+			} else if l.peekChar() == '.' {
+				l.readChar()
+				tok = token.Token{Type: token.ELLIPSIS, Literal: "...", LineNumber: l.RowNum}
+			} else {
+				tok = token.Token{Type: token.RANGE, Literal: "..", LineNumber: l.RowNum}
+			}
+		} else if isDigit(l.peekChar()) {
+			// fraction without the integer part, e.g. .5
+			position := l.position
+			l.readChar()
+			return l.invalidNumber(position)
+		} else {
+			tok = newToken(token.DOT, l.ch, l.RowNum)
+		}
 	case '"':
 		return l.readString()
 	case 0:
@@ -160,23 +287,78 @@ func (l *Lexer) NextToken() (tok token.Token) {
 			tok.LineNumber = l.RowNum
 			return tok
 		} else if isDigit(l.ch) {
+			position := l.position
 			tok.Type = token.INT
 			tok.Literal = l.readNumber()
 			tok.LineNumber = l.RowNum
+			// a dot followed by digits makes it a float, two dots are a range operator
+			// and a dot followed by a name is a method call, e.g. 5.times(f)
+			if l.ch == '.' && isDigit(l.peekChar()) {
+				l.readChar()
+				l.readNumber()
+				tok.Type = token.FLOAT
+				tok.Literal = l.input[position:l.position]
+			} else if l.ch == '.' && l.peekChar() != '.' && !isLetter(l.peekChar()) {
+				l.readChar()
+				return l.invalidNumber(position)
+			}
+			if l.strict && isLetter(l.ch) {
+				return l.invalidNumber(position)
+			}
 			return tok
 		} else {
-			msg := fmt.Sprintf("FATAL ERROR: illegal character: %q at line: %d\n\n", string(l.ch), l.RowNum)
-			tok = token.Token{Type: token.ILLEGAL, Literal: msg, LineNumber: l.RowNum}
+			tok = l.illegalCharacter()
 		}
 	}
 	l.readChar()
 	return tok
 }
 
-// Keep reading input as long as it's a word.
+// Returns an ILLEGAL token for the current character.
+func (l *Lexer) illegalCharacter() token.Token {
+	msg := fmt.Sprintf("FATAL ERROR: illegal character: %q at line: %d\n\n", string(l.ch), l.RowNum)
+
+	return token.Token{Type: token.ILLEGAL, Literal: msg, LineNumber: l.RowNum}
+}
+
+// Tokenize returns all the tokens of the input, the last one is EOF.
+func Tokenize(input string) []token.Token {
+	return New(input).Tokens()
+}
+
+// Tokens returns the remaining tokens of the input.
+// The last one is EOF, or the first ILLEGAL token as the lexer can't recover from it.
+func (l *Lexer) Tokens() []token.Token {
+	tokens := []token.Token{}
+
+	for {
+		tok := l.NextToken()
+		tokens = append(tokens, tok)
+
+		if tok.Type == token.EOF || tok.Type == token.ILLEGAL {
+			return tokens
+		}
+	}
+}
+
+// Stream returns a closed channel buffering the remaining tokens of the input,
+// the consumer can stop receiving at any point.
+func (l *Lexer) Stream() <-chan token.Token {
+	tokens := l.Tokens()
+	stream := make(chan token.Token, len(tokens))
+
+	for _, tok := range tokens {
+		stream <- tok
+	}
+	close(stream)
+
+	return stream
+}
+
+// Keep reading input as long as it's a word, digits are allowed after the first letter.
 func (l *Lexer) readIdent() string {
 	position := l.position
-	for isLetter(l.ch) {
+	for isLetter(l.ch) || isDigit(l.ch) {
 		l.readChar()
 	}
 	return l.input[position:l.position]
@@ -191,10 +373,40 @@ func (l *Lexer) readNumber() string {
 	return l.input[position:l.position]
 }
 
+// Reads the rest of the malformed number starting at given position and returns an ILLEGAL token.
+func (l *Lexer) invalidNumber(position int) token.Token {
+	for isLetter(l.ch) || isDigit(l.ch) {
+		l.readChar()
+	}
+
+	msg := fmt.Sprintf("FATAL ERROR: invalid number literal: %s at line: %d\n\n", l.input[position:l.position], l.RowNum)
+
+	return token.Token{Type: token.ILLEGAL, Literal: msg, LineNumber: l.RowNum}
+}
+
+// characters represented by escape sequences in string literals, e.g. \n
+var escapes = map[byte]byte{'n': '\n', 't': '\t', 'r': '\r', '\\': '\\', '"': '"', '$': '$'}
+
 func (l *Lexer) readString() token.Token {
-	position := l.position + 1
+	start := l.nextPosition
+	interpolated := false
+
 	for {
 		l.readChar()
+		if l.ch == '\\' {
+			l.readChar()
+			if _, ok := escapes[l.ch]; ok {
+				continue
+			} else if l.ch != 0 {
+				return l.unknownEscape()
+			}
+		}
+
+		if l.ch == '$' && l.peekChar() == '{' {
+			interpolated = true
+			l.skipInterpolation()
+		}
+
 		if l.ch == '"' {
 			break
 		} else if l.ch == 0 {
@@ -203,8 +415,115 @@ func (l *Lexer) readString() token.Token {
 			return token.Token{Type: token.ILLEGAL, Literal: msg, LineNumber: l.RowNum}
 		}
 	}
+	raw := l.input[start:l.position]
+	l.readChar()
+
+	if interpolated {
+		return token.Token{Type: token.TEMPLATE, Literal: raw, LineNumber: l.RowNum}
+	}
+	return token.Token{Type: token.STRING, Literal: unescape(raw), LineNumber: l.RowNum}
+}
+
+// Moves the lexer to the closing brace of embedded expression starting at the current "${",
+// or to the end of input if the expression isn't terminated.
+func (l *Lexer) skipInterpolation() {
+	end := interpolationEnd(l.input, l.position)
+	for l.ch != 0 && (end < 0 || l.position < end) {
+		l.readChar()
+	}
+}
+
+// interpolationEnd returns index of the brace closing embedded expression starting with "${" at given index,
+// or -1 if it isn't closed. Braces inside of nested string literals are skipped.
+func interpolationEnd(s string, start int) int {
+	depth := 0
+	for i := start + 1; i < len(s); i++ {
+		switch s[i] {
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		case '"':
+			for i++; i < len(s) && s[i] != '"'; i++ {
+				if s[i] == '\\' {
+					i++
+				}
+			}
+		}
+	}
+
+	return -1
+}
+
+// unescape replaces escape sequences of already validated string literal with characters they represent.
+func unescape(raw string) string {
+	var out bytes.Buffer
+	for i := 0; i < len(raw); i++ {
+		if raw[i] == '\\' {
+			i++
+			out.WriteByte(escapes[raw[i]])
+			continue
+		}
+		out.WriteByte(raw[i])
+	}
+
+	return out.String()
+}
+
+// TemplatePart is a piece of an interpolated string literal,
+// either text or source code of an embedded expression.
+type TemplatePart struct {
+	Value      string
+	Expression bool
+}
+
+// SplitTemplate splits literal of a TEMPLATE token into text and sources of embedded expressions.
+// Escape sequences in the text are replaced with characters they represent.
+func SplitTemplate(raw string) []TemplatePart {
+	var parts []TemplatePart
+	var text bytes.Buffer
+
+	for i := 0; i < len(raw); i++ {
+		switch {
+		case raw[i] == '\\' && i+1 < len(raw):
+			i++
+			text.WriteByte(escapes[raw[i]])
+		case raw[i] == '$' && i+1 < len(raw) && raw[i+1] == '{':
+			end := interpolationEnd(raw, i)
+			if end < 0 {
+				end = len(raw)
+			}
+			if text.Len() > 0 {
+				parts = append(parts, TemplatePart{Value: text.String()})
+				text.Reset()
+			}
+			parts = append(parts, TemplatePart{Value: raw[i+2 : end], Expression: true})
+			i = end
+		default:
+			text.WriteByte(raw[i])
+		}
+	}
+
+	if text.Len() > 0 {
+		parts = append(parts, TemplatePart{Value: text.String()})
+	}
+
+	return parts
+}
+
+// Reads the rest of the string literal with unknown escape sequence and returns an ILLEGAL token.
+func (l *Lexer) unknownEscape() token.Token {
+	msg := fmt.Sprintf("FATAL ERROR: unknown escape sequence: %q at line: %d\n\n", "\\"+string(l.ch), l.RowNum)
+
+	for l.ch != '"' && l.ch != 0 {
+		l.readChar()
+	}
 	l.readChar()
-	return token.Token{Type: token.STRING, Literal: l.input[position : l.position-1], LineNumber: l.RowNum}
+
+	return token.Token{Type: token.ILLEGAL, Literal: msg, LineNumber: l.RowNum}
 }
 
 func isLetter(ch byte) bool {
@@ -1,6 +1,7 @@
 package lexer
 
 import (
+	"runtime"
 	"testing"
 
 	"github.com/radlinskii/interpreter/token"
@@ -107,7 +108,7 @@ func TestNextToken2(t *testing.T) {
 }
 
 func TestNextToken3(t *testing.T) {
-	input := `!-*/5;
+	input := `!-*/%5;
 	5 < 10 > 	5;`
 
 	tests := []struct {
@@ -118,6 +119,7 @@ func TestNextToken3(t *testing.T) {
 		{token.MINUS, "-"},
 		{token.ASTERISK, "*"},
 		{token.SLASH, "/"},
+		{token.MODULO, "%"},
 		{token.INT, "5"},
 		{token.SEMICOLON, ";"},
 		{token.INT, "5"},
@@ -380,3 +382,504 @@ func TestHashTokens(t *testing.T) {
 		}
 	}
 }
+
+func TestShebangLine(t *testing.T) {
+	input := `#!/usr/bin/env junior
+const a = 5;
+#`
+
+	tests := []struct {
+		expectedType    token.Type
+		expectedLiteral string
+	}{
+		{token.CONST, "const"},
+		{token.IDENT, "a"},
+		{token.ASSIGN, "="},
+		{token.INT, "5"},
+		{token.SEMICOLON, ";"},
+		{token.ILLEGAL, "FATAL ERROR: illegal character: \"#\" at line: 3\n\n"},
+		{token.EOF, ""},
+	}
+
+	l := New(input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q", i, tt.expectedType, tok.Type)
+		}
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q", i, tt.expectedLiteral, tok.Literal)
+		}
+	}
+}
+
+func TestStrictNumberLiteral(t *testing.T) {
+	tests := []struct {
+		input           string
+		strict          bool
+		expectedType    token.Type
+		expectedLiteral string
+	}{
+		{"123abc;", false, token.INT, "123"},
+		{"123abc;", true, token.ILLEGAL, "FATAL ERROR: invalid number literal: 123abc at line: 1\n\n"},
+		{"123;", true, token.INT, "123"},
+	}
+
+	for i, tt := range tests {
+		l := New(tt.input)
+		if tt.strict {
+			l = NewStrict(tt.input)
+		}
+
+		tok := l.NextToken()
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q", i, tt.expectedType, tok.Type)
+		}
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q", i, tt.expectedLiteral, tok.Literal)
+		}
+	}
+}
+
+func TestIdentifiersWithDigits(t *testing.T) {
+	tests := []struct {
+		input           string
+		expectedType    token.Type
+		expectedLiteral string
+	}{
+		{"base64Encode;", token.IDENT, "base64Encode"},
+		{"x1;", token.IDENT, "x1"},
+		{"_2;", token.IDENT, "_2"},
+		{"1x;", token.INT, "1"},
+	}
+
+	for i, tt := range tests {
+		tok := New(tt.input).NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q", i, tt.expectedType, tok.Type)
+		}
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q", i, tt.expectedLiteral, tok.Literal)
+		}
+	}
+}
+
+func TestFloatTokens(t *testing.T) {
+	tests := []struct {
+		input           string
+		expectedType    token.Type
+		expectedLiteral string
+	}{
+		{"3.14;", token.FLOAT, "3.14"},
+		{"0.5;", token.FLOAT, "0.5"},
+		{"10;", token.INT, "10"},
+		{"1..5;", token.INT, "1"},
+		{"5.times(f);", token.INT, "5"},
+		{"3.;", token.ILLEGAL, "FATAL ERROR: invalid number literal: 3. at line: 1\n\n"},
+		{".5;", token.ILLEGAL, "FATAL ERROR: invalid number literal: .5 at line: 1\n\n"},
+	}
+
+	for i, tt := range tests {
+		tok := New(tt.input).NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q", i, tt.expectedType, tok.Type)
+		}
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q", i, tt.expectedLiteral, tok.Literal)
+		}
+	}
+}
+
+func TestRangeTokens(t *testing.T) {
+	input := `1..5; 1..=n; a.b; 5.times;`
+
+	tests := []struct {
+		expectedType    token.Type
+		expectedLiteral string
+	}{
+		{token.INT, "1"},
+		{token.RANGE, ".."},
+		{token.INT, "5"},
+		{token.SEMICOLON, ";"},
+		{token.INT, "1"},
+		{token.RANGEINCL, "..="},
+		{token.IDENT, "n"},
+		{token.SEMICOLON, ";"},
+		{token.IDENT, "a"},
+		{token.DOT, "."},
+		{token.IDENT, "b"},
+		{token.SEMICOLON, ";"},
+		{token.INT, "5"},
+		{token.DOT, "."},
+		{token.IDENT, "times"},
+		{token.SEMICOLON, ";"},
+		{token.EOF, ""},
+	}
+
+	l := New(input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q", i, tt.expectedType, tok.Type)
+		}
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q", i, tt.expectedLiteral, tok.Literal)
+		}
+	}
+}
+
+func TestMatchTokens(t *testing.T) {
+	input := `match xs { [h, ...t] => h; _ => 0 }`
+
+	tests := []struct {
+		expectedType    token.Type
+		expectedLiteral string
+	}{
+		{token.MATCH, "match"},
+		{token.IDENT, "xs"},
+		{token.LBRACE, "{"},
+		{token.LBRACKET, "["},
+		{token.IDENT, "h"},
+		{token.COMMA, ","},
+		{token.ELLIPSIS, "..."},
+		{token.IDENT, "t"},
+		{token.RBRACKET, "]"},
+		{token.ARROW, "=>"},
+		{token.IDENT, "h"},
+		{token.SEMICOLON, ";"},
+		{token.IDENT, "_"},
+		{token.ARROW, "=>"},
+		{token.INT, "0"},
+		{token.RBRACE, "}"},
+		{token.EOF, ""},
+	}
+
+	l := New(input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q", i, tt.expectedType, tok.Type)
+		}
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q", i, tt.expectedLiteral, tok.Literal)
+		}
+	}
+}
+
+func TestLogicalOperatorTokens(t *testing.T) {
+	input := `a && b || not c and d or e; &`
+
+	tests := []struct {
+		expectedType    token.Type
+		expectedLiteral string
+	}{
+		{token.IDENT, "a"},
+		{token.AND, "&&"},
+		{token.IDENT, "b"},
+		{token.OR, "||"},
+		{token.BANG, "not"},
+		{token.IDENT, "c"},
+		{token.AND, "and"},
+		{token.IDENT, "d"},
+		{token.OR, "or"},
+		{token.IDENT, "e"},
+		{token.SEMICOLON, ";"},
+		{token.ILLEGAL, "FATAL ERROR: illegal character: \"&\" at line: 1\n\n"},
+	}
+
+	l := New(input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q", i, tt.expectedType, tok.Type)
+		}
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q", i, tt.expectedLiteral, tok.Literal)
+		}
+	}
+}
+
+func TestTokenize(t *testing.T) {
+	tests := []struct {
+		input         string
+		expectedTypes []token.Type
+	}{
+		{"const a = 1;", []token.Type{token.CONST, token.IDENT, token.ASSIGN, token.INT, token.SEMICOLON, token.EOF}},
+		{"", []token.Type{token.EOF}},
+		{"a $ b", []token.Type{token.IDENT, token.ILLEGAL}},
+	}
+
+	for _, tt := range tests {
+		tokens := Tokenize(tt.input)
+
+		if len(tokens) != len(tt.expectedTypes) {
+			t.Fatalf("wrong number of tokens for %q. expected=%d, got=%d", tt.input, len(tt.expectedTypes), len(tokens))
+		}
+		for i, expected := range tt.expectedTypes {
+			if tokens[i].Type != expected {
+				t.Errorf("tokens[%d] - tokentype wrong. expected=%q, got=%q", i, expected, tokens[i].Type)
+			}
+		}
+	}
+}
+
+func TestTokens(t *testing.T) {
+	inputs := []string{
+		`const add = fun(a, b) { a + b; };
+		const result = if (add(1, 2) >= 3) { "${result}" } else { [1.5, true] };
+		for (x in 1..=3) { print(x); }`,
+		"const a = 1 $ 2;",
+		"",
+	}
+
+	for _, input := range inputs {
+		l := New(input)
+		expected := []token.Token{}
+		for {
+			tok := l.NextToken()
+			expected = append(expected, tok)
+			if tok.Type == token.EOF || tok.Type == token.ILLEGAL {
+				break
+			}
+		}
+
+		tokens := New(input).Tokens()
+
+		if len(tokens) != len(expected) {
+			t.Fatalf("wrong number of tokens for %q. expected=%d, got=%d", input, len(expected), len(tokens))
+		}
+		for i := range expected {
+			if tokens[i] != expected[i] {
+				t.Errorf("tokens[%d] wrong. expected=%+v, got=%+v", i, expected[i], tokens[i])
+			}
+		}
+	}
+}
+
+func TestStreamClosesAfterIllegalToken(t *testing.T) {
+	count := 0
+	for tok := range New("a $ b c").Stream() {
+		count++
+		if count == 2 && tok.Type != token.ILLEGAL {
+			t.Errorf("expected second token to be ILLEGAL. got=%q", tok.Type)
+		}
+	}
+
+	if count != 2 {
+		t.Errorf("expected stream to be closed after ILLEGAL token. got %d tokens", count)
+	}
+}
+
+func TestStreamCanBeAbandoned(t *testing.T) {
+	before := runtime.NumGoroutine()
+
+	for i := 0; i < 100; i++ {
+		tok := <-New("a b c").Stream()
+		if tok.Literal != "a" {
+			t.Fatalf("wrong first token. expected=%q, got=%q", "a", tok.Literal)
+		}
+	}
+
+	if after := runtime.NumGoroutine(); after > before {
+		t.Errorf("abandoned streams left goroutines running. before=%d, after=%d", before, after)
+	}
+}
+
+func TestTabWidth(t *testing.T) {
+	tests := []struct {
+		input          string
+		tabWidth       int
+		expectedColumn int
+	}{
+		{"x", 8, 1},
+		{"\tx", 1, 2},
+		{"\tx", 8, 9},
+		{"\t\tx", 8, 17},
+		{"ab\tx", 8, 9},
+		{"ab\tx", 4, 5},
+		{"const a = 1;\n\tx", 8, 9},
+	}
+
+	for _, tt := range tests {
+		l := New(tt.input)
+		l.SetTabWidth(tt.tabWidth)
+
+		for l.ch != 'x' {
+			l.readChar()
+		}
+
+		if l.ColNum != tt.expectedColumn {
+			t.Errorf("wrong column for %q with tab width %d. expected=%d, got=%d", tt.input, tt.tabWidth, tt.expectedColumn, l.ColNum)
+		}
+	}
+}
+
+func TestStringEscapes(t *testing.T) {
+	tests := []struct {
+		input           string
+		expectedType    token.Type
+		expectedLiteral string
+	}{
+		{`"line1\nline2"`, token.STRING, "line1\nline2"},
+		{`"a\tb"`, token.STRING, "a\tb"},
+		{`"a\rb"`, token.STRING, "a\rb"},
+		{`"back\\slash"`, token.STRING, "back\\slash"},
+		{`"say \"hi\""`, token.STRING, `say "hi"`},
+		{`"\\"`, token.STRING, "\\"},
+		{`"a\qb"`, token.ILLEGAL, "FATAL ERROR: unknown escape sequence: \"\\\\q\" at line: 1\n\n"},
+		{`"abc\`, token.ILLEGAL, "FATAL ERROR: string literal not terminated at line: 1\n\n"},
+		{`"abc\"`, token.ILLEGAL, "FATAL ERROR: string literal not terminated at line: 1\n\n"},
+	}
+
+	for _, tt := range tests {
+		tok := New(tt.input).NextToken()
+
+		if tok.Type != tt.expectedType || tok.Literal != tt.expectedLiteral {
+			t.Errorf("wrong token for %q. expected=%s %q, got=%s %q", tt.input, tt.expectedType, tt.expectedLiteral, tok.Type, tok.Literal)
+		}
+	}
+}
+
+func TestStringInterpolation(t *testing.T) {
+	tests := []struct {
+		input           string
+		expectedType    token.Type
+		expectedLiteral string
+	}{
+		{`"${name} is ${age}"`, token.TEMPLATE, "${name} is ${age}"},
+		{`"a ${f({"k": "}"})} b"`, token.TEMPLATE, `a ${f({"k": "}"})} b`},
+		{`"\${name}"`, token.STRING, "${name}"},
+		{`"$name {age}"`, token.STRING, "$name {age}"},
+		{`"${name"`, token.ILLEGAL, "FATAL ERROR: string literal not terminated at line: 1\n\n"},
+	}
+
+	for _, tt := range tests {
+		tok := New(tt.input).NextToken()
+
+		if tok.Type != tt.expectedType || tok.Literal != tt.expectedLiteral {
+			t.Errorf("wrong token for %q. expected=%s %q, got=%s %q", tt.input, tt.expectedType, tt.expectedLiteral, tok.Type, tok.Literal)
+		}
+	}
+}
+
+func TestSplitTemplate(t *testing.T) {
+	parts := SplitTemplate(`${name}:\t${a + 1} \${x}`)
+
+	expected := []TemplatePart{
+		{Value: "name", Expression: true},
+		{Value: ":\t"},
+		{Value: "a + 1", Expression: true},
+		{Value: " ${x}"},
+	}
+
+	if len(parts) != len(expected) {
+		t.Fatalf("wrong number of parts. expected=%d, got=%d", len(expected), len(parts))
+	}
+
+	for i, part := range parts {
+		if part != expected[i] {
+			t.Errorf("parts[%d] wrong. expected=%+v, got=%+v", i, expected[i], part)
+		}
+	}
+}
+
+func TestTokenColumns(t *testing.T) {
+	input := "x == \"ab c\";\n\tfoo(12 /* c */ >= y)"
+
+	tests := []struct {
+		expectedLiteral string
+		expectedLine    int
+		expectedColumn  int
+	}{
+		{"x", 1, 1},
+		{"==", 1, 3},
+		{"ab c", 1, 6},
+		{";", 1, 12},
+		{"foo", 2, 2},
+		{"(", 2, 5},
+		{"12", 2, 6},
+		{">=", 2, 17},
+		{"y", 2, 20},
+		{")", 2, 21},
+		{"", 2, 22},
+	}
+
+	l := New(input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - wrong literal. expected=%q, got=%q", i, tt.expectedLiteral, tok.Literal)
+		}
+		if tok.LineNumber != tt.expectedLine || tok.Column != tt.expectedColumn {
+			t.Errorf("tests[%d] - wrong position of %q. expected=%d:%d, got=%d:%d",
+				i, tok.Literal, tt.expectedLine, tt.expectedColumn, tok.LineNumber, tok.Column)
+		}
+	}
+}
+
+func TestConfigurableComments(t *testing.T) {
+	input := `# line comment
+const a = 10 / 2; # trailing comment
+<# block
+comment #> a;
+// not a comment`
+
+	tests := []struct {
+		expectedType    token.Type
+		expectedLiteral string
+	}{
+		{token.CONST, "const"},
+		{token.IDENT, "a"},
+		{token.ASSIGN, "="},
+		{token.INT, "10"},
+		{token.SLASH, "/"},
+		{token.INT, "2"},
+		{token.SEMICOLON, ";"},
+		{token.IDENT, "a"},
+		{token.SEMICOLON, ";"},
+		{token.SLASH, "/"},
+		{token.SLASH, "/"},
+		{token.BANG, "not"},
+		{token.IDENT, "a"},
+		{token.IDENT, "comment"},
+		{token.EOF, ""},
+	}
+
+	l := NewWithComments(input, CommentSyntax{Line: "#", BlockStart: "<#", BlockEnd: "#>"})
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q", i, tt.expectedType, tok.Type)
+		}
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q", i, tt.expectedLiteral, tok.Literal)
+		}
+	}
+
+	if l.RowNum != 5 {
+		t.Errorf("wrong line number. expected=5, got=%d", l.RowNum)
+	}
+}
+
+func TestBlockCommentsDisabled(t *testing.T) {
+	l := NewWithComments("/* a */", CommentSyntax{Line: "#"})
+
+	tok := l.NextToken()
+	if tok.Type != token.SLASH {
+		t.Fatalf("tokentype wrong. expected=%q, got=%q", token.SLASH, tok.Type)
+	}
+}
@@ -32,6 +32,7 @@ func main() {
 	program := p.ParseProgram()
 	if len(p.Errors()) == 0 {
 		env := object.NewEnvironment()
+		env.SetInput(os.Stdin)
 
 		evaluated := evaluator.EvalProgram(program, env)
 		fmt.Println(evaluated)
@@ -1,10 +1,17 @@
 package object
 
 import (
+	"bufio"
 	"bytes"
+	"context"
+	"errors"
 	"fmt"
 	"hash/fnv"
+	"io"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 
 	"github.com/radlinskii/interpreter/ast"
 )
@@ -15,10 +22,14 @@ type Type string
 const (
 	// INTEGER object type
 	INTEGER = "INTEGER"
+	// FLOAT object type
+	FLOAT = "FLOAT"
 	// BOOLEAN object type
 	BOOLEAN = "BOOLEAN"
 	// STRING object type
 	STRING = "STRING"
+	// BYTES object type
+	BYTES = "BYTES"
 	// NULL object type
 	NULL = "NULL"
 	// VOID object type
@@ -35,6 +46,18 @@ const (
 	ARRAY = "ARRAY"
 	// HASH object type
 	HASH = "HASH"
+	// ENV object type
+	ENV = "ENV"
+	// RECORD object type
+	RECORD = "RECORD"
+	// LAZY object type
+	LAZY = "LAZY"
+	// GENERATOR object type
+	GENERATOR = "GENERATOR"
+	// TASK object type
+	TASK = "TASK"
+	// CHANNEL object type
+	CHANNEL = "CHANNEL"
 )
 
 // Object interface is implemented by the objects.
@@ -61,6 +84,26 @@ func (i *Integer) Type() Type {
 	return INTEGER
 }
 
+// Float object.
+type Float struct {
+	Value float64
+}
+
+// Inspect returns value of a float, always with the fractional part, e.g. 5.0
+func (f *Float) Inspect() string {
+	s := strconv.FormatFloat(f.Value, 'f', -1, 64)
+	if !strings.ContainsAny(s, ".IN") {
+		s += ".0"
+	}
+
+	return s
+}
+
+// Type returns the float type.
+func (f *Float) Type() Type {
+	return FLOAT
+}
+
 // Boolean object.
 type Boolean struct {
 	Value bool
@@ -91,6 +134,26 @@ func (s *String) Type() Type {
 	return STRING
 }
 
+// Bytes object holding binary data, e.g. contents of a binary file.
+type Bytes struct {
+	Value []byte
+}
+
+// Inspect returns values of the bytes, e.g. bytes[65, 66].
+func (b *Bytes) Inspect() string {
+	values := make([]string, len(b.Value))
+	for i, v := range b.Value {
+		values[i] = strconv.Itoa(int(v))
+	}
+
+	return "bytes[" + strings.Join(values, ", ") + "]"
+}
+
+// Type returns the bytes type.
+func (b *Bytes) Type() Type {
+	return BYTES
+}
+
 // Null object.
 type Null struct{}
 
@@ -132,14 +195,34 @@ func (rv *Return) Type() Type {
 	return RETURN
 }
 
+// StackFrame describes function call during which an error occurred.
+type StackFrame struct {
+	Function   string
+	LineNumber int
+}
+
 // Error object.
+// Stack holds the calls the error propagated through, starting from the innermost one.
 type Error struct {
-	Message string
+	Message    string
+	LineNumber int // line where the error happened, 0 if unknown
+	Stack      []StackFrame
 }
 
-// Inspect returns error message.
+// Inspect returns error message followed by the stack trace.
 func (e *Error) Inspect() string {
-	return "\nERROR: " + e.Message + "\n"
+	var out bytes.Buffer
+
+	if e.LineNumber > 0 {
+		out.WriteString(fmt.Sprintf("\nERROR: line %d: %s\n", e.LineNumber, e.Message))
+	} else {
+		out.WriteString("\nERROR: " + e.Message + "\n")
+	}
+	for _, frame := range e.Stack {
+		out.WriteString(fmt.Sprintf("    at %s (line: %d)\n", frame.Function, frame.LineNumber))
+	}
+
+	return out.String()
 }
 
 // Type returns the Error object type.
@@ -148,10 +231,13 @@ func (e *Error) Type() Type {
 }
 
 // Function object.
+// Name is set when the function gets bound to a constant.
 type Function struct {
+	Name       string
 	Parameters []*ast.Identifier
 	Body       *ast.BlockStatement
 	Env        *Environment
+	Generator  bool
 }
 
 // Inspect returns the Function object image.
@@ -163,7 +249,11 @@ func (f *Function) Inspect() string {
 		params = append(params, p.String())
 	}
 
-	out.WriteString("fun(")
+	out.WriteString("fun")
+	if f.Name != "" {
+		out.WriteString(" " + f.Name)
+	}
+	out.WriteString("(")
 	out.WriteString(strings.Join(params, ", "))
 	out.WriteString(") ")
 	out.WriteString(f.Body.String())
@@ -178,27 +268,241 @@ func (f *Function) Type() Type {
 
 // Environment is a map of known objects.
 type Environment struct {
-	store map[string]Object
-	outer *Environment
+	store     map[string]Object
+	outer     *Environment
+	coroutine *Coroutine
+	budget    *IterationBudget
+	ctx       context.Context
+	mu        *sync.RWMutex // guards the store once functions of the program run concurrently
+	warnings  *Warnings
+	output    *Output
+	input     *bufio.Reader
+	scheduler *Scheduler
+	// trueDivision makes dividing integers which aren't evenly divisible result in a float
+	trueDivision bool
+	// booleanArithmetic makes booleans act as integers in arithmetic, true is 1 and false is 0
+	booleanArithmetic bool
+}
+
+// Warnings collects messages about suspicious code found during evaluation, which doesn't stop it like errors do.
+type Warnings struct {
+	mu       sync.Mutex
+	messages []string
+	seen     map[string]bool
+}
+
+// Add appends the warning message unless it was already added since the last Drain,
+// e.g. when the suspicious code is evaluated in a loop. It's safe to call from multiple goroutines.
+func (w *Warnings) Add(message string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.seen[message] {
+		return
+	}
+	if w.seen == nil {
+		w.seen = make(map[string]bool)
+	}
+	w.seen[message] = true
+
+	w.messages = append(w.messages, message)
+}
+
+// Drain returns the collected warning messages and forgets them.
+func (w *Warnings) Drain() []string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	messages := w.messages
+	w.messages = nil
+	w.seen = nil
+
+	return messages
+}
+
+// Output collects what the program prints, it's safe to write to from multiple goroutines.
+type Output struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+// Write appends p to the output.
+func (o *Output) Write(p []byte) (int, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	return o.buf.Write(p)
+}
+
+// Drain returns the collected output and forgets it.
+func (o *Output) Drain() string {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	s := o.buf.String()
+	o.buf.Reset()
+
+	return s
+}
+
+// IterationBudget limits the total number of iterations of all loops, e.g. to stop runaway loops in sandboxed programs.
+type IterationBudget struct {
+	Max  int64
+	Used int64 // updated atomically, as spawned functions share the budget
 }
 
 // NewEnvironment returns new Environment instance
+// Every Environment created this way collects output and schedules spawned functions of the programs evaluated in it separately.
 func NewEnvironment() *Environment {
 	s := make(map[string]Object)
-	return &Environment{store: s, outer: nil}
+	return &Environment{store: s, outer: nil, mu: &sync.RWMutex{}, output: &Output{}, scheduler: NewScheduler()}
+}
+
+// NewConcurrentEnvironment returns new Environment instance whose bindings can be read and set from multiple goroutines from the start,
+// e.g. when Go code evaluates programs in it concurrently. Bindings of other Environments are guarded once their programs spawn a function.
+func NewConcurrentEnvironment() *Environment {
+	env := NewEnvironment()
+	env.scheduler.markConcurrent()
+	return env
 }
 
 // NewEnclosedEnvironment returns new Environment instance
 func NewEnclosedEnvironment(outer *Environment) *Environment {
-	env := NewEnvironment()
-	env.outer = outer
+	s := make(map[string]Object)
+	return &Environment{store: s, outer: outer, mu: &sync.RWMutex{}, scheduler: outer.Scheduler()}
+}
+
+// NewGeneratorEnvironment returns new Environment instance in which yield statements suspend given coroutine.
+func NewGeneratorEnvironment(outer *Environment, co *Coroutine) *Environment {
+	env := NewEnclosedEnvironment(outer)
+	env.coroutine = co
 	return env
 }
 
+// Coroutine returns the coroutine suspended by yield statements evaluated in the Environment,
+// or nil if it's not inside of a generator function.
+func (e *Environment) Coroutine() *Coroutine {
+	if e.coroutine == nil && e.outer != nil {
+		return e.outer.Coroutine()
+	}
+	return e.coroutine
+}
+
+// SetIterationBudget limits iterations of loops evaluated in the Environment and its descendants.
+func (e *Environment) SetIterationBudget(budget *IterationBudget) {
+	e.budget = budget
+}
+
+// IterationBudget returns the budget shared by loops evaluated in the Environment, or nil if loops are not limited.
+func (e *Environment) IterationBudget() *IterationBudget {
+	if e.budget == nil && e.outer != nil {
+		return e.outer.IterationBudget()
+	}
+	return e.budget
+}
+
+// SetContext makes evaluation in the Environment and its descendants stop once the context is done.
+// It's meant for Environments of a single evaluation, e.g. of a function call,
+// Environments shared between evaluations should be viewed with WithContext instead.
+func (e *Environment) SetContext(ctx context.Context) {
+	e.ctx = ctx
+}
+
+// WithContext returns a view of the Environment sharing its bindings and settings,
+// in which evaluation stops once the context is done. The Environment itself isn't changed,
+// so programs evaluated in it at the same time keep their own contexts.
+func (e *Environment) WithContext(ctx context.Context) *Environment {
+	view := *e
+	view.ctx = ctx
+	return &view
+}
+
+// Context returns the context cancelling evaluation in the Environment, or nil if it can't be cancelled.
+func (e *Environment) Context() context.Context {
+	if e.ctx == nil && e.outer != nil {
+		return e.outer.Context()
+	}
+	return e.ctx
+}
+
+// SetTrueDivision makes `/` between integers result in a float when they aren't evenly divisible.
+// Only the setting of the outermost Environment counts.
+func (e *Environment) SetTrueDivision(on bool) {
+	e.trueDivision = on
+}
+
+// TrueDivision checks if `/` between integers can result in a float.
+func (e *Environment) TrueDivision() bool {
+	if e.outer != nil {
+		return e.outer.TrueDivision()
+	}
+	return e.trueDivision
+}
+
+// SetBooleanArithmetic makes booleans usable in arithmetic as integers, true is 1 and false is 0.
+// Only the setting of the outermost Environment counts.
+func (e *Environment) SetBooleanArithmetic(on bool) {
+	e.booleanArithmetic = on
+}
+
+// BooleanArithmetic checks if booleans can be used in arithmetic.
+func (e *Environment) BooleanArithmetic() bool {
+	if e.outer != nil {
+		return e.outer.BooleanArithmetic()
+	}
+	return e.booleanArithmetic
+}
+
+// SetWarnings makes warnings found in the Environment and its descendants collected by w.
+func (e *Environment) SetWarnings(w *Warnings) {
+	e.warnings = w
+}
+
+// Warnings returns the collector of warnings found in the Environment, or nil if warnings are ignored.
+func (e *Environment) Warnings() *Warnings {
+	if e.warnings == nil && e.outer != nil {
+		return e.outer.Warnings()
+	}
+	return e.warnings
+}
+
+// Output returns where the programs evaluated in the Environment print to.
+func (e *Environment) Output() *Output {
+	if e.output == nil && e.outer != nil {
+		return e.outer.Output()
+	}
+	return e.output
+}
+
+// Scheduler returns the Scheduler of functions spawned by the programs evaluated in the Environment.
+func (e *Environment) Scheduler() *Scheduler {
+	if e.scheduler == nil && e.outer != nil {
+		return e.outer.Scheduler()
+	}
+	return e.scheduler
+}
+
+// SetInput makes the programs evaluated in the Environment and its descendants read their input from r.
+func (e *Environment) SetInput(r io.Reader) {
+	if br, ok := r.(*bufio.Reader); ok {
+		e.input = br
+		return
+	}
+	e.input = bufio.NewReader(r)
+}
+
+// Input returns where the programs evaluated in the Environment read from, or nil if they have no input.
+func (e *Environment) Input() *bufio.Reader {
+	if e.input == nil && e.outer != nil {
+		return e.outer.Input()
+	}
+	return e.input
+}
+
 // Get returns value of given key from Enviroment's map.
 // If not found, looks for value in Environment's ancestor.
 func (e *Environment) Get(name string) (Object, bool) {
-	obj, ok := e.store[name]
+	obj, ok := e.ShallowGet(name)
 	if !ok && e.outer != nil {
 		obj, ok = e.outer.Get(name)
 	}
@@ -207,6 +511,11 @@ func (e *Environment) Get(name string) (Object, bool) {
 
 // ShallowGet returns value of given key from Enviroment's map.
 func (e *Environment) ShallowGet(name string) (Object, bool) {
+	if e.concurrent() {
+		e.mu.RLock()
+		defer e.mu.RUnlock()
+	}
+
 	obj, ok := e.store[name]
 
 	return obj, ok
@@ -214,10 +523,341 @@ func (e *Environment) ShallowGet(name string) (Object, bool) {
 
 // Set puts the value of given key in Enviroment's map.
 func (e *Environment) Set(name string, val Object) Object {
+	if e.concurrent() {
+		e.mu.Lock()
+		defer e.mu.Unlock()
+	}
+
 	e.store[name] = val
 	return val
 }
 
+// concurrent checks if the store has to be guarded, as functions of the program may access it from multiple goroutines.
+func (e *Environment) concurrent() bool {
+	return e.scheduler != nil && e.scheduler.Concurrent()
+}
+
+// Snapshot returns a copy of the Environment's own bindings that can be restored later.
+func (e *Environment) Snapshot() *Environment {
+	if e.concurrent() {
+		e.mu.RLock()
+		defer e.mu.RUnlock()
+	}
+
+	s := make(map[string]Object, len(e.store))
+	for name, val := range e.store {
+		s[name] = val
+	}
+
+	return &Environment{store: s, outer: e.outer, coroutine: e.coroutine, budget: e.budget, ctx: e.ctx, mu: &sync.RWMutex{}, warnings: e.warnings, output: e.output, input: e.input, scheduler: e.scheduler,
+		trueDivision: e.trueDivision, booleanArithmetic: e.booleanArithmetic}
+}
+
+// Isolate returns a copy of the Environment with copies of all its ancestors,
+// so another goroutine can read it while the original one keeps changing.
+func (e *Environment) Isolate() *Environment {
+	isolated := e.Snapshot()
+	if e.outer != nil {
+		isolated.outer = e.outer.Isolate()
+	}
+
+	return isolated
+}
+
+// Restore brings back the bindings saved with Snapshot.
+func (e *Environment) Restore(snapshot *Environment) {
+	store := snapshot.Snapshot().store
+
+	if e.concurrent() {
+		e.mu.Lock()
+		defer e.mu.Unlock()
+	}
+
+	e.store = store
+}
+
+// Env object exposes an Environment to the programs.
+type Env struct {
+	Value *Environment
+}
+
+// Type returns the Env object type.
+func (e *Env) Type() Type {
+	return ENV
+}
+
+// Inspect returns the Env object representation.
+func (e *Env) Inspect() string {
+	return "environment"
+}
+
+// Record is an instance of a type declared with the record statement.
+type Record struct {
+	TypeName   string
+	FieldNames []string
+	Fields     map[string]Object
+}
+
+// Type returns the Record object type.
+func (r *Record) Type() Type {
+	return RECORD
+}
+
+// Inspect returns the Record object representation, e.g. Point(x: 1, y: 2).
+func (r *Record) Inspect() string {
+	var out bytes.Buffer
+
+	fields := []string{}
+	for _, name := range r.FieldNames {
+		fields = append(fields, fmt.Sprintf("%s: %s", name, r.Fields[name].Inspect()))
+	}
+
+	out.WriteString(r.TypeName)
+	out.WriteString("(")
+	out.WriteString(strings.Join(fields, ", "))
+	out.WriteString(")")
+
+	return out.String()
+}
+
+// Lazy is a value computed by calling the Function on first use, the result is cached in Value.
+type Lazy struct {
+	Function Object
+	Value    Object
+}
+
+// Type returns the Lazy object type.
+func (l *Lazy) Type() Type {
+	return LAZY
+}
+
+// Inspect returns the computed value representation or "lazy" if it wasn't computed yet.
+func (l *Lazy) Inspect() string {
+	if l.Value == nil {
+		return "lazy"
+	}
+
+	return l.Value.Inspect()
+}
+
+// Generator is a call of a generator function running in its own goroutine, driven by its Coroutine.
+// Done is set once the function has finished.
+type Generator struct {
+	*Coroutine
+	Done bool
+}
+
+// Coroutine passes control between a generator function and the caller of `next`.
+// Every value sent to Resume lets the function run until it yields a value to Yields,
+// the Yields channel is closed when the function finishes. Closing Stop makes the function finish instead of yielding.
+type Coroutine struct {
+	Resume chan struct{}
+	Yields chan Object
+	Stop   chan struct{}
+}
+
+// Type returns the Generator object type.
+func (g *Generator) Type() Type {
+	return GENERATOR
+}
+
+// Inspect returns the Generator object representation.
+func (g *Generator) Inspect() string {
+	return "generator"
+}
+
+// Task is a call of a function spawned in its own goroutine.
+// Result holds its return value once the function has Finished, both are guarded by the program's Scheduler.
+type Task struct {
+	Finished bool
+	Result   Object
+}
+
+// Type returns the Task object type.
+func (t *Task) Type() Type {
+	return TASK
+}
+
+// Inspect returns the Task object representation.
+func (t *Task) Inspect() string {
+	return "task"
+}
+
+// Channel passes values between spawned functions, its Queue is guarded by the program's Scheduler.
+type Channel struct {
+	Capacity int
+	// values sent but not received yet in the order they were sent, the first Capacity of them are buffered
+	// and the others belong to senders waiting for the buffer to free up
+	Queue []*Message
+}
+
+// Message is a value sent to a Channel.
+type Message struct {
+	Value    Object
+	Received bool
+}
+
+// Type returns the Channel object type.
+func (c *Channel) Type() Type {
+	return CHANNEL
+}
+
+// Inspect returns the Channel object representation.
+func (c *Channel) Inspect() string {
+	return "channel"
+}
+
+// Buffered checks if the message is received or fits in the buffer of the channel, so its sender can go on.
+func (c *Channel) Buffered(m *Message) bool {
+	if m.Received {
+		return true
+	}
+
+	for i, queued := range c.Queue {
+		if queued == m {
+			return i < c.Capacity
+		}
+	}
+
+	return false
+}
+
+// Remove takes back the message that wasn't received, e.g. because its sender stopped waiting.
+func (c *Channel) Remove(m *Message) {
+	for i, queued := range c.Queue {
+		if queued == m {
+			c.Queue = append(c.Queue[:i:i], c.Queue[i+1:]...)
+			return
+		}
+	}
+}
+
+// ErrDeadlock is returned by Scheduler.Wait when all functions of the program are waiting, so none of them can go on.
+var ErrDeadlock = errors.New("deadlock: all functions are waiting for channels or tasks")
+
+// Scheduler keeps track of the functions of a program running in separate goroutines, i.e. the main one and the spawned ones,
+// so functions waiting for each other can be told that they never will be woken up.
+// Channels and tasks of the program are guarded by its lock.
+type Scheduler struct {
+	mu         sync.Mutex
+	cond       *sync.Cond
+	running    int // functions that aren't waiting, the main one is counted from the start
+	waiting    map[*waiter]bool
+	concurrent int32 // set atomically once a function is spawned
+}
+
+// waiter is a function waiting until its condition is ready.
+type waiter struct {
+	ready func() bool
+}
+
+// NewScheduler returns a Scheduler counting the calling goroutine as the main function of the program.
+func NewScheduler() *Scheduler {
+	s := &Scheduler{running: 1, waiting: make(map[*waiter]bool)}
+	s.cond = sync.NewCond(&s.mu)
+	return s
+}
+
+// Lock locks the channels and tasks of the program.
+func (s *Scheduler) Lock() {
+	s.mu.Lock()
+}
+
+// Unlock unlocks the channels and tasks of the program and wakes up the waiting functions to check if they changed.
+func (s *Scheduler) Unlock() {
+	s.cond.Broadcast()
+	s.mu.Unlock()
+}
+
+// Go calls f in a new goroutine, counting it as a running function of the program.
+func (s *Scheduler) Go(f func()) {
+	s.markConcurrent()
+
+	s.Lock()
+	s.running++
+	s.Unlock()
+
+	go func() {
+		defer func() {
+			s.Lock()
+			s.running--
+			s.Unlock()
+		}()
+
+		f()
+	}()
+}
+
+// Concurrent checks if functions of the program may run in multiple goroutines, so the state they share has to be guarded.
+func (s *Scheduler) Concurrent() bool {
+	return atomic.LoadInt32(&s.concurrent) == 1
+}
+
+func (s *Scheduler) markConcurrent() {
+	atomic.StoreInt32(&s.concurrent, 1)
+}
+
+// Wait blocks until ready reports true, it has to be called with the Scheduler locked and returns with it locked.
+// It fails with the error of the context once it's done, or with ErrDeadlock when no function of the program can go on.
+func (s *Scheduler) Wait(ctx context.Context, ready func() bool) error {
+	if ready() {
+		return nil
+	}
+
+	w := &waiter{ready: ready}
+	s.running--
+	s.waiting[w] = true
+	defer func() {
+		delete(s.waiting, w)
+		s.running++
+	}()
+
+	if ctx != nil && ctx.Done() != nil {
+		stop := make(chan struct{})
+		defer close(stop)
+
+		go func() {
+			select {
+			case <-ctx.Done():
+				s.Lock()
+				s.Unlock()
+			case <-stop:
+			}
+		}()
+	}
+
+	// the other waiting functions have to check if this one was the last running
+	s.cond.Broadcast()
+
+	for !ready() {
+		if ctx != nil && ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if s.deadlocked() {
+			return ErrDeadlock
+		}
+
+		s.cond.Wait()
+	}
+
+	return nil
+}
+
+// deadlocked checks if all functions are waiting for something that isn't ready.
+func (s *Scheduler) deadlocked() bool {
+	if s.running > 0 {
+		return false
+	}
+
+	for w := range s.waiting {
+		if w.ready() {
+			return false
+		}
+	}
+
+	return true
+}
+
 // Builtin is a wrapper over built-in function.
 type Builtin struct {
 	Fn BuiltinFunction
@@ -303,8 +943,15 @@ type HashPair struct {
 }
 
 // Hash represents the Hash Object Type.
+// If KeyType is set only keys of that type can be stored in the Hash.
 type Hash struct {
-	Pairs map[HashKey]HashPair
+	Pairs   map[HashKey]HashPair
+	KeyType Type
+}
+
+// AcceptsKey checks if the key satisfies Hash's key type constraint.
+func (h *Hash) AcceptsKey(key Object) bool {
+	return h.KeyType == "" || h.KeyType == key.Type()
 }
 
 // Type returns the Hash object type.
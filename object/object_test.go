@@ -1,6 +1,10 @@
 package object
 
-import "testing"
+import (
+	"fmt"
+	"sync"
+	"testing"
+)
 
 func TestStringHashKey(t *testing.T) {
 	hello1 := &String{Value: "Hello World"}
@@ -28,3 +32,144 @@ func TestStringHashKey(t *testing.T) {
 		t.Errorf("strings with different content have same hash keys")
 	}
 }
+
+func TestEnclosedEnvironmentShadowing(t *testing.T) {
+	outer := NewEnvironment()
+	outer.Set("x", &Integer{Value: 1})
+	outer.Set("y", &Integer{Value: 2})
+
+	inner := NewEnclosedEnvironment(outer)
+	inner.Set("x", &Integer{Value: 10})
+
+	if x, _ := inner.Get("x"); x.(*Integer).Value != 10 {
+		t.Errorf("inner binding of x not found. got=%s", x.Inspect())
+	}
+	if y, ok := inner.Get("y"); !ok || y.(*Integer).Value != 2 {
+		t.Errorf("outer binding of y not visible in inner environment")
+	}
+	if x, _ := outer.Get("x"); x.(*Integer).Value != 1 {
+		t.Errorf("shadowing x in inner environment changed outer binding. got=%s", x.Inspect())
+	}
+	if _, ok := inner.ShallowGet("y"); ok {
+		t.Errorf("ShallowGet found binding of outer environment")
+	}
+	if _, ok := outer.Get("z"); ok {
+		t.Errorf("unknown name found in environment")
+	}
+}
+
+// run with -race to detect unguarded access
+func TestIsolatedEnvironment(t *testing.T) {
+	env := NewEnvironment()
+	env.Set("x", &Integer{Value: 1})
+	inner := NewEnclosedEnvironment(env)
+	isolated := inner.Isolate()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for j := 0; j < 100; j++ {
+				if val, ok := isolated.Get("x"); !ok || val.(*Integer).Value != 1 {
+					t.Errorf("binding of x changed in isolated environment")
+				}
+			}
+		}()
+	}
+
+	for j := 0; j < 100; j++ {
+		env.Set("x", &Integer{Value: int64(j)})
+		env.Set(fmt.Sprintf("y%d", j), &Integer{Value: int64(j)})
+	}
+	wg.Wait()
+
+	if _, ok := isolated.Get("y0"); ok {
+		t.Errorf("binding set after isolating found in isolated environment")
+	}
+}
+
+// run with -race to detect unguarded access
+func TestConcurrentEnvironment(t *testing.T) {
+	env := NewConcurrentEnvironment()
+	inner := NewEnclosedEnvironment(env)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			for j := 0; j < 100; j++ {
+				name := fmt.Sprintf("x%d_%d", i, j)
+				env.Set(name, &Integer{Value: int64(j)})
+				if val, ok := inner.Get(name); !ok || val.(*Integer).Value != int64(j) {
+					t.Errorf("binding of %s not found", name)
+				}
+				env.Snapshot()
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	snapshot := env.Snapshot()
+	env.Set("y", &Integer{Value: 1})
+	env.Restore(snapshot)
+
+	if _, ok := env.Get("y"); ok {
+		t.Errorf("binding set after snapshot not removed by restore")
+	}
+	if val, ok := env.Get("x7_99"); !ok || val.(*Integer).Value != 99 {
+		t.Errorf("binding set before snapshot not restored")
+	}
+}
+
+// run with -race to detect unguarded access
+func TestEnvironmentOfSpawningProgram(t *testing.T) {
+	env := NewEnvironment()
+	if env.Scheduler().Concurrent() {
+		t.Fatalf("expected environment not to be guarded before spawning")
+	}
+
+	done := make(chan bool)
+	env.Scheduler().Go(func() {
+		for j := 0; j < 100; j++ {
+			env.Set(fmt.Sprintf("x%d", j), &Integer{Value: int64(j)})
+		}
+		done <- true
+	})
+
+	inner := NewEnclosedEnvironment(env)
+	for j := 0; j < 100; j++ {
+		inner.Get(fmt.Sprintf("x%d", j))
+	}
+	<-done
+
+	if val, ok := inner.Get("x99"); !ok || val.(*Integer).Value != 99 {
+		t.Errorf("binding set by spawned function not found")
+	}
+}
+
+func TestHashKeysOfDifferentTypes(t *testing.T) {
+	one := &Integer{Value: 1}
+	otherOne := &Integer{Value: 1}
+	two := &Integer{Value: 2}
+	truth := &Boolean{Value: true}
+
+	if one.HashKey() != otherOne.HashKey() {
+		t.Errorf("integers with same value have different hash keys")
+	}
+	if one.HashKey() == two.HashKey() {
+		t.Errorf("integers with different values have same hash keys")
+	}
+	if truth.HashKey() != (&Boolean{Value: true}).HashKey() {
+		t.Errorf("booleans with same value have different hash keys")
+	}
+	if truth.HashKey() == (&Boolean{Value: false}).HashKey() {
+		t.Errorf("booleans with different values have same hash keys")
+	}
+	if one.HashKey() == truth.HashKey() {
+		t.Errorf("integer 1 and boolean true have same hash keys")
+	}
+}
@@ -2,6 +2,7 @@ package parser
 
 import (
 	"fmt"
+	"math"
 	"strconv"
 	"strings"
 
@@ -14,38 +15,63 @@ const (
 	_ int = iota
 	// LOWEST == 1 default precedence
 	LOWEST
-	// EQUALS == 2 precedence for operators [==,!=]
+	// TERNARY == 2 precedence for operator "?:"
+	TERNARY
+	// OR == 3 precedence for operators [||,or]
+	OR
+	// AND == 4 precedence for operators [&&,and]
+	AND
+	// EQUALS == 5 precedence for operators [==,!=]
 	EQUALS
-	// LESSGREATER == 3 precedence for operators [>,<,>=,<=]
+	// LESSGREATER == 6 precedence for operators [>,<,>=,<=]
 	LESSGREATER
-	// SUM == 4 precedence for operators [+,"infixed" -]
+	// RANGE == 7 precedence for operators [..,..=]
+	RANGE
+	// SUM == 8 precedence for operators [+,"infixed" -]
 	SUM
-	// PRODUCT == 5 precedence for operators [*,/]
+	// PRODUCT == 9 precedence for operators [*,/,%,div]
 	PRODUCT
-	// PREFIX == 6 precedence for operators ["prefixed" -,!]
+	// PREFIX == 10 precedence for operators ["prefixed" -,!,not]
 	PREFIX
-	// CALL == 7 precedence for operator (
+	// CALL == 11 precedence for operator (
 	CALL
-	// INDEX == 8 precedence for "[x]" opertor
+	// INDEX == 12 precedence for "[x]" opertor
 	INDEX
+	// METHOD == 13 precedence for "." operator
+	METHOD
 )
 
 // list of built-in functions defined in evaluator/builtins.go
-var builtins = map[string]bool{"len": true, "print": true, "first": true, "last": true, "rest": true}
+var builtins = map[string]bool{"len": true, "print": true, "puts": true, "first": true, "last": true, "rest": true, "push": true, "map": true, "filter": true,
+	"readFile": true, "readFileBytes": true, "bytes": true, "base64Encode": true, "base64Decode": true, "base64DecodeBytes": true, "hash": true, "compose": true, "type": true, "str": true, "int": true, "readInt": true, "readFloat": true, "split": true, "join": true, "objectCount": true, "reduce": true, "contains": true, "index": true, "write": true, "spawn": true, "wait": true, "channel": true, "send": true, "recv": true, "writeFile": true, "typedHash": true, "put": true,
+	"flatMap": true, "flatten": true, "chunk": true,
+	"take": true, "drop": true, "groupBy": true,
+	"popcount": true, "clz": true, "ctz": true, "lazy": true, "next": true,
+	"jsonStringify": true, "template": true, "times": true,
+	"hex": true, "bin": true, "oct": true,
+	"currentEnv": true, "envGet": true, "envSet": true}
 
 var precedences = map[token.Type]int{
-	token.EQ:       EQUALS,
-	token.NEQ:      EQUALS,
-	token.LTE:      LESSGREATER,
-	token.GTE:      LESSGREATER,
-	token.LT:       LESSGREATER,
-	token.GT:       LESSGREATER,
-	token.PLUS:     SUM,
-	token.MINUS:    SUM,
-	token.SLASH:    PRODUCT,
-	token.ASTERISK: PRODUCT,
-	token.LPAREN:   CALL,
-	token.LBRACKET: INDEX,
+	token.QUESTION:  TERNARY,
+	token.OR:        OR,
+	token.AND:       AND,
+	token.EQ:        EQUALS,
+	token.NEQ:       EQUALS,
+	token.LTE:       LESSGREATER,
+	token.GTE:       LESSGREATER,
+	token.LT:        LESSGREATER,
+	token.GT:        LESSGREATER,
+	token.RANGE:     RANGE,
+	token.RANGEINCL: RANGE,
+	token.PLUS:      SUM,
+	token.MINUS:     SUM,
+	token.SLASH:     PRODUCT,
+	token.ASTERISK:  PRODUCT,
+	token.MODULO:    PRODUCT,
+	token.DIV:       PRODUCT,
+	token.LPAREN:    CALL,
+	token.LBRACKET:  INDEX,
+	token.DOT:       METHOD,
 }
 
 type prefixParseFunc func() ast.Expression
@@ -61,6 +87,9 @@ type Parser struct {
 
 	prefixParseFuncs map[token.Type]prefixParseFunc
 	infixParseFuncs  map[token.Type]infixParseFunc
+
+	// set when a yield statement is parsed in the body of current function
+	yields bool
 }
 
 func (p *Parser) nextToken() {
@@ -91,9 +120,17 @@ func New(l *lexer.Lexer) *Parser {
 	p.registerPrefix(token.IDENT, p.parseIdentifier)
 
 	p.registerPrefix(token.INT, p.parseIntegerLiteral)
+	p.registerPrefix(token.FLOAT, p.parseFloatLiteral)
 	p.registerPrefix(token.BOOLEAN, p.parseBooleanLiteral)
 	p.registerPrefix(token.STRING, p.parseStringLiteral)
+	p.registerPrefix(token.TEMPLATE, p.parseTemplateLiteral)
 	p.registerPrefix(token.FUNCTION, p.parseFunctionLiteral)
+	p.registerPrefix(token.PURE, p.parsePureFunctionLiteral)
+	p.registerPrefix(token.WITH, p.parseWithExpression)
+	p.registerPrefix(token.DO, p.parseDoExpression)
+	p.registerPrefix(token.MATCH, p.parseMatchExpression)
+	p.registerPrefix(token.TYPESWITCH, p.parseTypeSwitchExpression)
+	p.registerPrefix(token.IF, p.parseIfExpression)
 
 	p.registerPrefix(token.LPAREN, p.parseGroupedExpression)
 	p.registerPrefix(token.LBRACKET, p.parseArrayLiteral)
@@ -104,7 +141,13 @@ func New(l *lexer.Lexer) *Parser {
 
 	p.registerInfix(token.LPAREN, p.parseCallExpression)
 	p.registerInfix(token.LBRACKET, p.parseIndexExpression)
+	p.registerInfix(token.DOT, p.parseMethodCallExpression)
+	p.registerInfix(token.RANGE, p.parseRangeExpression)
+	p.registerInfix(token.RANGEINCL, p.parseRangeExpression)
+	p.registerInfix(token.QUESTION, p.parseConditionalExpression)
 
+	p.registerInfix(token.OR, p.parseInfixExpression)
+	p.registerInfix(token.AND, p.parseInfixExpression)
 	p.registerInfix(token.EQ, p.parseInfixExpression)
 	p.registerInfix(token.NEQ, p.parseInfixExpression)
 	p.registerInfix(token.LTE, p.parseInfixExpression)
@@ -115,6 +158,8 @@ func New(l *lexer.Lexer) *Parser {
 	p.registerInfix(token.MINUS, p.parseInfixExpression)
 	p.registerInfix(token.SLASH, p.parseInfixExpression)
 	p.registerInfix(token.ASTERISK, p.parseInfixExpression)
+	p.registerInfix(token.MODULO, p.parseInfixExpression)
+	p.registerInfix(token.DIV, p.parseInfixExpression)
 
 	return p
 }
@@ -125,10 +170,16 @@ func (p *Parser) ParseProgram() *ast.Program {
 	program.Statements = []ast.Statement{}
 
 	for !p.curTokenIs(token.EOF) {
+		errorsCount := len(p.errors)
+
 		stmnt := p.parseStatement()
 		if stmnt != nil {
 			program.Statements = append(program.Statements, stmnt)
 		}
+
+		if len(p.errors) > errorsCount {
+			p.synchronize()
+		}
 		p.nextToken()
 	}
 
@@ -137,6 +188,14 @@ func (p *Parser) ParseProgram() *ast.Program {
 	return program
 }
 
+// synchronize skips tokens until the end of erroneous statement,
+// so the parser can resume with the next one instead of reporting errors caused by the first one.
+func (p *Parser) synchronize() {
+	for !p.curTokenIs(token.SEMICOLON) && !p.curTokenIs(token.RBRACE) && !p.curTokenIs(token.EOF) {
+		p.nextToken()
+	}
+}
+
 // checkIfIllegal kills the parser if illegal character was found.
 func (p *Parser) checkIfIllegal() {
 	if p.curToken.Type == token.ILLEGAL {
@@ -184,6 +243,14 @@ func (p *Parser) parseStatement() ast.Statement {
 		return p.parseIfStatement()
 	case token.RETURN:
 		return p.parseReturnStatement()
+	case token.FOR:
+		return p.parseForInStatement()
+	case token.WHILE:
+		return p.parseWhileStatement()
+	case token.RECORD:
+		return p.parseRecordStatement()
+	case token.YIELD:
+		return p.parseYieldStatement()
 	default:
 		return p.parseExpressionStatement()
 	}
@@ -212,7 +279,7 @@ func (p *Parser) expectPeek(t token.Type) bool {
 
 // creates an error and adds it to the parser errors list
 func (p *Parser) peekError(t token.Type) {
-	msg := fmt.Sprintf("unexpected token: %q (expected: %q) at line: %d", p.peekToken.Type, t, p.lexer.RowNum)
+	msg := fmt.Sprintf("unexpected token: %q (expected: %q) at line: %d", p.peekToken.Type, t, p.peekToken.LineNumber)
 	p.errors = append(p.errors, msg)
 }
 
@@ -223,6 +290,8 @@ func (p *Parser) checkIfOverridesBuiltin() {
 	}
 }
 
+// semicolonError reports missing semicolon at the end of a statement.
+// It shouldn't be called when parsing the statement already failed, as it would only repeat the error.
 func (p *Parser) semicolonError() {
 	if p.curToken.Type != token.SEMICOLON {
 		msg := fmt.Sprintf("expected semicolon at line: %d", p.curToken.LineNumber)
@@ -230,9 +299,46 @@ func (p *Parser) semicolonError() {
 	}
 }
 
-// parses production of var statement --> "var" <ident> "=" <expression> ";"
+// parses production of const statement --> "const" <declaration> ("," <declaration>)... ";"
+// where declaration --> <ident> [":" <type>] "=" <expression>
 func (p *Parser) parseConstStatement() ast.Statement {
-	stmnt := &ast.ConstStatement{Token: p.curToken}
+	constToken := p.curToken
+	errorsCount := len(p.errors)
+
+	stmnt := p.parseConstDeclaration(constToken)
+	if stmnt == nil {
+		return nil
+	}
+
+	var result ast.Statement = stmnt
+	if p.peekTokenIs(token.COMMA) {
+		group := &ast.ConstGroupStatement{Token: constToken, Declarations: []*ast.ConstStatement{stmnt}}
+
+		for p.peekTokenIs(token.COMMA) {
+			p.nextToken()
+
+			stmnt := p.parseConstDeclaration(constToken)
+			if stmnt == nil {
+				return nil
+			}
+			group.Declarations = append(group.Declarations, stmnt)
+		}
+
+		result = group
+	}
+
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	} else if len(p.errors) == errorsCount {
+		p.semicolonError()
+	}
+
+	return result
+}
+
+// parses single declaration of const statement --> <ident> "=" <expression>
+func (p *Parser) parseConstDeclaration(constToken token.Token) *ast.ConstStatement {
+	stmnt := &ast.ConstStatement{Token: constToken}
 
 	if !p.expectPeek(token.IDENT) {
 		return nil
@@ -242,6 +348,16 @@ func (p *Parser) parseConstStatement() ast.Statement {
 
 	stmnt.Name = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
 
+	if p.peekTokenIs(token.COLON) {
+		p.nextToken()
+
+		if !p.expectPeek(token.IDENT) {
+			return nil
+		}
+
+		stmnt.Type = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+	}
+
 	if !p.expectPeek(token.ASSIGN) {
 		return nil
 	}
@@ -250,9 +366,56 @@ func (p *Parser) parseConstStatement() ast.Statement {
 
 	stmnt.Value = p.parseExpression(LOWEST)
 
+	return stmnt
+}
+
+// parses production of yield statement --> "yield" <expression> ";"
+func (p *Parser) parseYieldStatement() ast.Statement {
+	stmnt := &ast.YieldStatement{Token: p.curToken}
+	p.yields = true
+
+	p.nextToken()
+
+	errorsCount := len(p.errors)
+	stmnt.Value = p.parseExpression(LOWEST)
+	if stmnt.Value == nil {
+		return nil
+	}
+
 	if p.peekTokenIs(token.SEMICOLON) {
 		p.nextToken()
-	} else {
+	} else if len(p.errors) == errorsCount {
+		p.semicolonError()
+	}
+
+	return stmnt
+}
+
+// parses production of record statement --> "record" <identifier> "(" <identifiers> ")" ";"
+func (p *Parser) parseRecordStatement() ast.Statement {
+	stmnt := &ast.RecordStatement{Token: p.curToken}
+	errorsCount := len(p.errors)
+
+	if !p.expectPeek(token.IDENT) {
+		return nil
+	}
+
+	p.checkIfOverridesBuiltin()
+
+	stmnt.Name = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+
+	if !p.expectPeek(token.LPAREN) {
+		return nil
+	}
+
+	stmnt.Fields = p.parseFunctionParameters()
+	if stmnt.Fields == nil {
+		return nil
+	}
+
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	} else if len(p.errors) == errorsCount {
 		p.semicolonError()
 	}
 
@@ -268,11 +431,12 @@ func (p *Parser) parseReturnStatement() *ast.ReturnStatement {
 		return stmnt
 	}
 
+	errorsCount := len(p.errors)
 	stmnt.ReturnValue = p.parseExpression(LOWEST)
 
 	if p.peekTokenIs(token.SEMICOLON) {
 		p.nextToken()
-	} else {
+	} else if len(p.errors) == errorsCount {
 		p.semicolonError()
 	}
 
@@ -285,11 +449,13 @@ func (p *Parser) parseReturnStatement() *ast.ReturnStatement {
 func (p *Parser) parseExpressionStatement() *ast.ExpressionStatement {
 	stmnt := &ast.ExpressionStatement{Token: p.curToken}
 
+	errorsCount := len(p.errors)
 	stmnt.Expression = p.parseExpression(LOWEST)
 
+	// semicolon can be omitted after the last expression in a block
 	if p.peekTokenIs(token.SEMICOLON) {
 		p.nextToken()
-	} else {
+	} else if len(p.errors) == errorsCount && !p.peekTokenIs(token.RBRACE) {
 		p.semicolonError()
 	}
 
@@ -359,17 +525,379 @@ func (p *Parser) parseIfStatement() ast.Statement {
 	return stmnt
 }
 
+// parses if statement used as an expression, e.g. as an element of array literal
+func (p *Parser) parseIfExpression() ast.Expression {
+	stmnt, ok := p.parseIfStatement().(*ast.IfStatement)
+	if !ok {
+		return nil
+	}
+
+	return &ast.IfExpression{Token: stmnt.Token, Condition: stmnt.Condition, Consequence: stmnt.Consequence, Alternative: stmnt.Alternative}
+}
+
+// parses production of conditional expression --> <expression> "?" <expression> ":" <expression>
+func (p *Parser) parseConditionalExpression(condition ast.Expression) ast.Expression {
+	exp := &ast.ConditionalExpression{Token: p.curToken, Condition: condition}
+
+	p.nextToken()
+	exp.Consequence = p.parseExpression(LOWEST)
+
+	if !p.expectPeek(token.COLON) {
+		return nil
+	}
+
+	// parsing alternative with the lowest precedence makes the operator right-associative
+	p.nextToken()
+	exp.Alternative = p.parseExpression(LOWEST)
+
+	return exp
+}
+
+// parses production of for-in loop --> "for" "(" <ident> "in" <expression> ")" <block>
+func (p *Parser) parseForInStatement() ast.Statement {
+	stmnt := &ast.ForInStatement{Token: p.curToken}
+
+	if !p.expectPeek(token.LPAREN) {
+		return nil
+	}
+
+	if !p.expectPeek(token.IDENT) {
+		return nil
+	}
+
+	p.checkIfOverridesBuiltin()
+
+	stmnt.Variable = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+
+	if p.peekTokenIs(token.ASSIGN) {
+		return p.parseForStatement(stmnt.Token, stmnt.Variable)
+	}
+
+	if !p.expectPeek(token.IN) {
+		return nil
+	}
+
+	p.nextToken()
+	stmnt.Iterable = p.parseExpression(LOWEST)
+
+	if !p.expectPeek(token.RPAREN) {
+		return nil
+	}
+
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+
+	stmnt.Body = p.parseBlockStatement()
+
+	return stmnt
+}
+
+// parses production of for loop --> "for" "(" <ident> "=" <expression> ";" <expression> ";" <expression> ")" <block>
+// starting at the variable's name
+func (p *Parser) parseForStatement(tok token.Token, variable *ast.Identifier) ast.Statement {
+	stmnt := &ast.ForStatement{Token: tok, Variable: variable}
+
+	p.nextToken()
+	p.nextToken()
+	stmnt.Init = p.parseExpression(LOWEST)
+
+	if !p.expectPeek(token.SEMICOLON) {
+		return nil
+	}
+
+	p.nextToken()
+	stmnt.Condition = p.parseExpression(LOWEST)
+
+	if !p.expectPeek(token.SEMICOLON) {
+		return nil
+	}
+
+	p.nextToken()
+	stmnt.Update = p.parseExpression(LOWEST)
+
+	if !p.expectPeek(token.RPAREN) {
+		return nil
+	}
+
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+
+	stmnt.Body = p.parseBlockStatement()
+
+	return stmnt
+}
+
+// parses production of while loop --> "while" "(" <expression> ")" <block>
+func (p *Parser) parseWhileStatement() ast.Statement {
+	stmnt := &ast.WhileStatement{Token: p.curToken}
+
+	if !p.expectPeek(token.LPAREN) {
+		return nil
+	}
+
+	p.nextToken()
+	stmnt.Condition = p.parseExpression(LOWEST)
+
+	if !p.expectPeek(token.RPAREN) {
+		return nil
+	}
+
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+
+	stmnt.Body = p.parseBlockStatement()
+
+	return stmnt
+}
+
+// parses production of do expression --> "do" <block>
+func (p *Parser) parseDoExpression() ast.Expression {
+	exp := &ast.DoExpression{Token: p.curToken}
+
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+
+	exp.Body = p.parseBlockStatement()
+
+	return exp
+}
+
+// parses production of with expression --> "with" "(" <ident> "=" <expression> ... ")" <block>
+func (p *Parser) parseWithExpression() ast.Expression {
+	exp := &ast.WithExpression{Token: p.curToken}
+
+	if !p.expectPeek(token.LPAREN) {
+		return nil
+	}
+
+	for {
+		if !p.expectPeek(token.IDENT) {
+			return nil
+		}
+
+		p.checkIfOverridesBuiltin()
+		exp.Names = append(exp.Names, &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal})
+
+		if !p.expectPeek(token.ASSIGN) {
+			return nil
+		}
+
+		p.nextToken()
+		exp.Values = append(exp.Values, p.parseExpression(LOWEST))
+
+		if !p.peekTokenIs(token.COMMA) {
+			break
+		}
+		p.nextToken()
+	}
+
+	if !p.expectPeek(token.RPAREN) {
+		return nil
+	}
+
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+
+	exp.Body = p.parseBlockStatement()
+
+	return exp
+}
+
+// parses production of match expression --> "match" <expression> "{" <pattern> "=>" <expression> (";" <pattern> "=>" <expression>)... "}"
+func (p *Parser) parseMatchExpression() ast.Expression {
+	exp := &ast.MatchExpression{Token: p.curToken}
+
+	p.nextToken()
+	exp.Subject = p.parseExpression(LOWEST)
+
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+
+	for !p.peekTokenIs(token.RBRACE) {
+		p.nextToken()
+
+		arm := &ast.MatchArm{Pattern: p.parsePattern()}
+		if arm.Pattern == nil {
+			return nil
+		}
+
+		if !p.expectPeek(token.ARROW) {
+			return nil
+		}
+
+		p.nextToken()
+		arm.Body = p.parseExpression(LOWEST)
+		exp.Arms = append(exp.Arms, arm)
+
+		if !p.peekTokenIs(token.RBRACE) && !p.expectPeek(token.SEMICOLON) {
+			return nil
+		}
+	}
+
+	if !p.expectPeek(token.RBRACE) {
+		return nil
+	}
+
+	return exp
+}
+
+// "case" and "default" are recognized only inside of typeswitch, so they aren't reserved.
+func (p *Parser) parseTypeSwitchExpression() ast.Expression {
+	exp := &ast.TypeSwitchExpression{Token: p.curToken}
+
+	if !p.expectPeek(token.LPAREN) {
+		return nil
+	}
+
+	p.nextToken()
+	exp.Subject = p.parseExpression(LOWEST)
+
+	if !p.expectPeek(token.RPAREN) || !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+
+	for !p.peekTokenIs(token.RBRACE) {
+		if !p.expectPeek(token.IDENT) {
+			return nil
+		}
+
+		switch {
+		case exp.Default != nil:
+			msg := fmt.Sprintf("default has to be the last case of typeswitch at line: %d", p.curToken.LineNumber)
+			p.errors = append(p.errors, msg)
+			return nil
+		case p.curToken.Literal == "case":
+			if !p.expectPeek(token.IDENT) {
+				return nil
+			}
+
+			c := &ast.TypeCase{Type: &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}}
+			if !p.expectPeek(token.COLON) {
+				return nil
+			}
+
+			p.nextToken()
+			c.Body = p.parseExpression(LOWEST)
+			exp.Cases = append(exp.Cases, c)
+		case p.curToken.Literal == "default":
+			if !p.expectPeek(token.COLON) {
+				return nil
+			}
+
+			p.nextToken()
+			exp.Default = p.parseExpression(LOWEST)
+		default:
+			msg := fmt.Sprintf("unexpected token: %q (expected: \"case\" or \"default\") at line: %d", p.curToken.Literal, p.curToken.LineNumber)
+			p.errors = append(p.errors, msg)
+			return nil
+		}
+
+		if !p.peekTokenIs(token.RBRACE) && !p.expectPeek(token.SEMICOLON) {
+			return nil
+		}
+	}
+
+	if !p.expectPeek(token.RBRACE) {
+		return nil
+	}
+
+	return exp
+}
+
+// parses pattern of the match expression starting from current token.
+// Identifiers bind matched values, except for "_" which matches anything without binding it.
+func (p *Parser) parsePattern() ast.Expression {
+	switch p.curToken.Type {
+	case token.IDENT:
+		ident := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+		if !p.peekTokenIs(token.LPAREN) {
+			p.checkIfOverridesBuiltin()
+			return ident
+		}
+
+		p.nextToken()
+		pattern := &ast.RecordPattern{Token: ident.Token, Name: ident}
+		for !p.peekTokenIs(token.RPAREN) {
+			p.nextToken()
+
+			field := p.parsePattern()
+			if field == nil {
+				return nil
+			}
+			pattern.Fields = append(pattern.Fields, field)
+
+			if !p.peekTokenIs(token.RPAREN) && !p.expectPeek(token.COMMA) {
+				return nil
+			}
+		}
+		p.nextToken()
+
+		return pattern
+	case token.LBRACKET:
+		pattern := &ast.ArrayPattern{Token: p.curToken}
+		for !p.peekTokenIs(token.RBRACKET) {
+			p.nextToken()
+
+			if p.curTokenIs(token.ELLIPSIS) {
+				if !p.expectPeek(token.IDENT) {
+					return nil
+				}
+				p.checkIfOverridesBuiltin()
+				pattern.Rest = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+
+				break
+			}
+
+			element := p.parsePattern()
+			if element == nil {
+				return nil
+			}
+			pattern.Elements = append(pattern.Elements, element)
+
+			if !p.peekTokenIs(token.RBRACKET) && !p.expectPeek(token.COMMA) {
+				return nil
+			}
+		}
+
+		if !p.expectPeek(token.RBRACKET) {
+			return nil
+		}
+
+		return pattern
+	case token.INT, token.FLOAT, token.STRING, token.BOOLEAN, token.MINUS:
+		return p.parseExpression(PREFIX)
+	default:
+		p.noPrefixParseFuncError(p.curToken)
+		return nil
+	}
+}
+
 func (p *Parser) parseBlockStatement() *ast.BlockStatement {
 	block := &ast.BlockStatement{Token: p.curToken}
 	block.Statements = []ast.Statement{}
 
 	p.nextToken()
 
-	for !p.curTokenIs(token.RBRACE) {
+	for !p.curTokenIs(token.RBRACE) && !p.curTokenIs(token.EOF) {
+		errorsCount := len(p.errors)
+
 		stmnt := p.parseStatement()
 		if stmnt != nil {
 			block.Statements = append(block.Statements, stmnt)
 		}
+
+		if len(p.errors) > errorsCount {
+			p.synchronize()
+			if p.curTokenIs(token.RBRACE) {
+				break
+			}
+		}
 		p.nextToken()
 	}
 
@@ -410,25 +938,45 @@ func (p *Parser) noPrefixParseFuncError(t token.Token) {
 // Creates a PrefixExpression with current token as prefix operator
 // and expression as the right side of the PrefixExpression starting from next token. --> "!<expression>"
 func (p *Parser) parsePrefixExpression() ast.Expression {
+	// keyword forms of operators, e.g. "not", share the operator with their symbolic counterparts
 	expression := &ast.PrefixExpression{
 		Token:    p.curToken,
-		Operator: p.curToken.Literal,
+		Operator: string(p.curToken.Type),
 	}
 
 	p.nextToken()
 
+	if expression.Operator == token.MINUS && p.isMinIntMagnitude() {
+		// the magnitude doesn't fit in an integer, so it's stored wrapped around to the minimum integer,
+		// which is also what negating it evaluates to
+		expression.Right = &ast.IntegerLiteral{Token: p.curToken, Value: math.MinInt64}
+		return expression
+	}
+
 	expression.Right = p.parseExpression(PREFIX)
 
 	return expression
 }
 
+// isMinIntMagnitude checks if the current token is an integer literal equal to the magnitude of the minimum integer,
+// which only can be parsed negated directly, without any operators binding stronger than the minus.
+func (p *Parser) isMinIntMagnitude() bool {
+	if !p.curTokenIs(token.INT) || p.peekPrecedence() > PREFIX {
+		return false
+	}
+
+	value, err := strconv.ParseUint(p.curToken.Literal, 0, 64)
+
+	return err == nil && value == -math.MinInt64
+}
+
 // It's given left side expression as an argument.
 // It creates InfixExpression with given expression on the left and current token as the operator.
 // Then it calls parseExpression with precedence of the current operator to assign it on it's right side.
 func (p *Parser) parseInfixExpression(left ast.Expression) ast.Expression {
 	expression := &ast.InfixExpression{
 		Token:    p.curToken,
-		Operator: p.curToken.Literal,
+		Operator: string(p.curToken.Type),
 		Left:     left,
 	}
 
@@ -439,6 +987,21 @@ func (p *Parser) parseInfixExpression(left ast.Expression) ast.Expression {
 	return expression
 }
 
+// Parses range of integers --> <expression> ".." <expression> or <expression> "..=" <expression>
+func (p *Parser) parseRangeExpression(start ast.Expression) ast.Expression {
+	exp := &ast.RangeExpression{
+		Token:     p.curToken,
+		Start:     start,
+		Inclusive: p.curTokenIs(token.RANGEINCL),
+	}
+
+	precedence := p.curPrecedence()
+	p.nextToken()
+	exp.End = p.parseExpression(precedence)
+
+	return exp
+}
+
 // Parses integer tokens into the IntegerLiterals AST nodes.
 func (p *Parser) parseIntegerLiteral() ast.Expression {
 	lit := &ast.IntegerLiteral{Token: p.curToken}
@@ -465,6 +1028,46 @@ func (p *Parser) parseStringLiteral() ast.Expression {
 	return &ast.StringLiteral{Token: p.curToken, Value: p.curToken.Literal}
 }
 
+func (p *Parser) parseTemplateLiteral() ast.Expression {
+	tl := &ast.TemplateLiteral{Token: p.curToken}
+
+	for _, part := range lexer.SplitTemplate(p.curToken.Literal) {
+		if part.Expression {
+			tl.Parts = append(tl.Parts, p.parseInterpolation(part.Value))
+			continue
+		}
+
+		tok := p.curToken
+		tok.Type = token.STRING
+		tok.Literal = part.Value
+		tl.Parts = append(tl.Parts, &ast.StringLiteral{Token: tok, Value: part.Value})
+	}
+
+	return tl
+}
+
+// parseInterpolation parses source of an expression embedded in a string with a separate parser,
+// its errors are reported by the current one.
+func (p *Parser) parseInterpolation(source string) ast.Expression {
+	l := lexer.New(source)
+	l.RowNum = p.curToken.LineNumber
+	sub := New(l)
+
+	if sub.curTokenIs(token.EOF) {
+		msg := fmt.Sprintf("empty expression in string interpolation at line: %d", p.curToken.LineNumber)
+		p.errors = append(p.errors, msg)
+		return nil
+	}
+
+	exp := sub.parseExpression(LOWEST)
+	if len(sub.errors) == 0 {
+		sub.expectPeek(token.EOF)
+	}
+	p.errors = append(p.errors, sub.errors...)
+
+	return exp
+}
+
 func (p *Parser) parseFunctionLiteral() ast.Expression {
 	fl := &ast.FunctionLiteral{Token: p.curToken}
 
@@ -478,7 +1081,44 @@ func (p *Parser) parseFunctionLiteral() ast.Expression {
 		return nil
 	}
 
+	outerYields := p.yields
+	p.yields = false
+
 	fl.Body = p.parseBlockStatement()
+	fl.Generator = p.yields
+
+	p.yields = outerYields
+
+	return fl
+}
+
+func (p *Parser) parseFloatLiteral() ast.Expression {
+	lit := &ast.FloatLiteral{Token: p.curToken}
+
+	value, err := strconv.ParseFloat(p.curToken.Literal, 64)
+	if err != nil {
+		msg := fmt.Sprintf("could not parse: %q as float at line: %d", p.curToken.Literal, p.curToken.LineNumber)
+		p.errors = append(p.errors, msg)
+
+		return nil
+	}
+
+	lit.Value = value
+
+	return lit
+}
+
+// parses production of pure function --> "pure" "fun" "(" <identifiers...> ")" <block>
+func (p *Parser) parsePureFunctionLiteral() ast.Expression {
+	if !p.expectPeek(token.FUNCTION) {
+		return nil
+	}
+
+	fl, ok := p.parseFunctionLiteral().(*ast.FunctionLiteral)
+	if !ok {
+		return nil
+	}
+	fl.Pure = true
 
 	return fl
 }
@@ -522,6 +1162,27 @@ func (p *Parser) parseCallExpression(function ast.Expression) ast.Expression {
 	return exp
 }
 
+// Parses method call on a receiver --> <expression> "." <ident> "(" <expressions...> ")"
+func (p *Parser) parseMethodCallExpression(receiver ast.Expression) ast.Expression {
+	exp := &ast.MethodCallExpression{Token: p.curToken, Receiver: receiver}
+
+	if !p.expectPeek(token.IDENT) {
+		return nil
+	}
+
+	exp.Method = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+
+	if !p.peekTokenIs(token.LPAREN) {
+		return &ast.PropertyExpression{Token: exp.Token, Receiver: receiver, Property: exp.Method}
+	}
+
+	p.nextToken()
+
+	exp.Arguments = p.parseExpressionList(token.RPAREN)
+
+	return exp
+}
+
 func (p *Parser) parseExpressionList(end token.Type) []ast.Expression {
 	list := []ast.Expression{}
 
@@ -2,6 +2,7 @@ package parser
 
 import (
 	"fmt"
+	"math"
 	"testing"
 
 	"github.com/radlinskii/interpreter/ast"
@@ -65,6 +66,57 @@ func TestConstStatements(t *testing.T) {
 	}
 }
 
+func TestConstStatementWithTypeAnnotation(t *testing.T) {
+	input := "const x: Int = 5;"
+
+	program := testParsingInput(t, input, 1)
+
+	stmnt := program.Statements[0]
+	if !testConstStatement(t, stmnt, "x") {
+		return
+	}
+
+	cs := stmnt.(*ast.ConstStatement)
+	if cs.Type == nil {
+		t.Fatalf("cs.Type is nil")
+	}
+	testIdentifier(t, cs.Type, "Int")
+	testLiteralExpression(t, cs.Value, 5)
+
+	if cs.String() != input {
+		t.Errorf("cs.String() wrong. expected=%q, got=%q", input, cs.String())
+	}
+}
+
+func TestConstGroupStatement(t *testing.T) {
+	input := "const a = 1, b = 2, c = a + b;"
+
+	program := testParsingInput(t, input, 1)
+
+	stmnt, ok := program.Statements[0].(*ast.ConstGroupStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not *ast.ConstGroupStatement. got=%T", program.Statements[0])
+	}
+
+	if len(stmnt.Declarations) != 3 {
+		t.Fatalf("wrong number of declarations, expected 3. got=%d", len(stmnt.Declarations))
+	}
+
+	for i, name := range []string{"a", "b", "c"} {
+		if !testConstStatement(t, stmnt.Declarations[i], name) {
+			return
+		}
+	}
+
+	testLiteralExpression(t, stmnt.Declarations[0].Value, 1)
+	testLiteralExpression(t, stmnt.Declarations[1].Value, 2)
+	testInfixExpression(t, stmnt.Declarations[2].Value, "a", "+", "b")
+
+	if stmnt.String() != "const a = 1, b = 2, c = (a + b);" {
+		t.Errorf("stmnt.String() wrong. got=%q", stmnt.String())
+	}
+}
+
 func testConstStatement(t *testing.T, s ast.Statement, name string) bool {
 	if s.TokenLiteral() != "const" {
 		t.Errorf("s.TokenLiteral not 'const'. got='%q'", s.TokenLiteral())
@@ -162,6 +214,44 @@ func TestIntegerLiteralExpression(t *testing.T) {
 	}
 }
 
+func TestMinimumIntegerLiteral(t *testing.T) {
+	program := testParsingInput(t, "-9223372036854775808;", 1)
+
+	stmnt := program.Statements[0].(*ast.ExpressionStatement)
+	exp, ok := stmnt.Expression.(*ast.PrefixExpression)
+	if !ok {
+		t.Fatalf("stmnt.Expression is not *ast.PrefixExpression. got=%T", stmnt.Expression)
+	}
+
+	lit, ok := exp.Right.(*ast.IntegerLiteral)
+	if !ok {
+		t.Fatalf("exp.Right is not *ast.IntegerLiteral. got=%T", exp.Right)
+	}
+	if lit.Value != math.MinInt64 {
+		t.Errorf("lit.Value not %d. got=%d", int64(math.MinInt64), lit.Value)
+	}
+}
+
+func TestFloatLiteralExpression(t *testing.T) {
+	program := testParsingInput(t, "3.14;", 1)
+
+	stmnt, ok := program.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not *ast.ExpressionStatement. got=%q", program.Statements[0])
+	}
+
+	fl, ok := stmnt.Expression.(*ast.FloatLiteral)
+	if !ok {
+		t.Fatalf("stmnt.Expression is not *ast.FloatLiteral. got=%T", stmnt.Expression)
+	}
+	if fl.Value != 3.14 {
+		t.Errorf("fl.Value not %f. got=%f", 3.14, fl.Value)
+	}
+	if fl.TokenLiteral() != "3.14" {
+		t.Errorf("fl.TokenLiteral not %q. got=%q", "3.14", fl.TokenLiteral())
+	}
+}
+
 func TestBooleanLiteralExpression(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -219,6 +309,7 @@ func TestParsingInfixExpressions(t *testing.T) {
 		{"5+5;", 5, "+", 5},
 		{"5-5;", 5, "-", 5},
 		{"5*5;", 5, "*", 5},
+		{"5 % 5;", 5, "%", 5},
 		{"5/5;", 5, "/", 5},
 		{"5>5;", 5, ">", 5},
 		{"5<5;", 5, "<", 5},
@@ -258,6 +349,7 @@ func TestOperatorPrecedenceParsing(t *testing.T) {
 		{"a + b + c;", "((a + b) + c)"},
 		{"a + b - c;", "((a + b) - c)"},
 		{"a + -b;", "(a + (-b))"},
+		{"a + b div c * d;", "(a + ((b div c) * d))"},
 		{"a * b + c;", "((a * b) + c)"},
 		{"a + b / c;", "(a + (b / c))"},
 		{"5 > 4 == 2 < 3;", "((5 > 4) == (2 < 3))"},
@@ -272,6 +364,20 @@ func TestOperatorPrecedenceParsing(t *testing.T) {
 		{"add(a+b+c*d/f, g);", "add(((a + b) + ((c * d) / f)), g)"},
 		{"a * [1, 2, 3, 4][b*c] * d;", "((a * ([1, 2, 3, 4][(b * c)])) * d)"},
 		{"add(a * b[2], b[1], 2 * [1, 2][1]);", "add((a * (b[2])), (b[1]), (2 * ([1, 2][1])))"},
+		{"a.filter(f).map(g);", "a.filter(f).map(g)"},
+		{"fun(x) { x + 1 };", "fun(x)(x + 1)"},
+		{"1..5;", "(1..5)"},
+		{"a + 1..=b * 2;", "((a + 1)..=(b * 2))"},
+		{"0..n == [];", "((0..n) == [])"},
+		{"a + b.map(f)[0];", "(a + (b.map(f)[0]))"},
+		{"a.x + b.y * c;", "(a.x + (b.y * c))"},
+		{"a + b % c * d;", "(a + ((b % c) * d))"},
+		{"a || b && c == d;", "(a || (b && (c == d)))"},
+		{"a or b and not c;", "(a || (b && (!c)))"},
+		{"not a == b;", "((!a) == b)"},
+		{"a || b ? c + 1 : d;", "((a || b) ? (c + 1) : d)"},
+		{"a ? b : c ? d : e;", "(a ? b : (c ? d : e))"},
+		{"a ? b ? c : d : e;", "(a ? (b ? c : d) : e)"},
 	}
 
 	for _, tt := range tests {
@@ -576,6 +682,35 @@ func TestStringLiteralExpression(t *testing.T) {
 	}
 }
 
+func TestTemplateLiteralExpression(t *testing.T) {
+	input := `"${name} is ${age + 1}!";`
+
+	program := testParsingInput(t, input, 1)
+
+	stmnt, ok := program.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not *ast.ExpressionStatement. got=%T", program.Statements[0])
+	}
+
+	tl, ok := stmnt.Expression.(*ast.TemplateLiteral)
+	if !ok {
+		t.Fatalf("stmnt.Expression is not *ast.TemplateLiteral. got=%T", stmnt.Expression)
+	}
+
+	if len(tl.Parts) != 4 {
+		t.Fatalf("tl.Parts has wrong length. expected=4, got=%d", len(tl.Parts))
+	}
+
+	testIdentifier(t, tl.Parts[0], "name")
+	testStringLiteral(t, tl.Parts[1], " is ")
+	testInfixExpression(t, tl.Parts[2], "age", "+", 1)
+	testStringLiteral(t, tl.Parts[3], "!")
+
+	if tl.String() != "${name} is ${(age + 1)}!" {
+		t.Errorf("tl.String() wrong. got=%q", tl.String())
+	}
+}
+
 func testStringLiteral(t *testing.T, exp ast.Expression, expected string) bool {
 	string, ok := exp.(*ast.StringLiteral)
 	if !ok {
@@ -615,6 +750,47 @@ func TestParsingArrayLiterals(t *testing.T) {
 	testStringLiteral(t, array.Elements[3], "word")
 }
 
+func TestParsingArrayLiteralWithConditionals(t *testing.T) {
+	input := `[if (c) { 1 } else { 2 }, cond ? 3 : 4];`
+
+	program := testParsingInput(t, input, 1)
+
+	stmnt, ok := program.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not *ast.ExpressionStatement. got=%T", program.Statements[0])
+	}
+	array, ok := stmnt.Expression.(*ast.ArrayLiteral)
+	if !ok {
+		t.Fatalf("exp not *ast.ArrayLiteral. got=%T", stmnt.Expression)
+	}
+
+	if len(array.Elements) != 2 {
+		t.Fatalf("len(array.Elements) not 2, got=%d", len(array.Elements))
+	}
+
+	ie, ok := array.Elements[0].(*ast.IfExpression)
+	if !ok {
+		t.Fatalf("array.Elements[0] is not *ast.IfExpression. got=%T", array.Elements[0])
+	}
+	testIdentifier(t, ie.Condition, "c")
+	if ie.Alternative == nil {
+		t.Fatalf("ie.Alternative is nil")
+	}
+	consequence, ok := ie.Consequence.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("ie.Consequence.Statements[0] is not *ast.ExpressionStatement. got=%T", ie.Consequence.Statements[0])
+	}
+	testIntegerLiteral(t, consequence.Expression, 1)
+
+	ce, ok := array.Elements[1].(*ast.ConditionalExpression)
+	if !ok {
+		t.Fatalf("array.Elements[1] is not *ast.ConditionalExpression. got=%T", array.Elements[1])
+	}
+	testIdentifier(t, ce.Condition, "cond")
+	testIntegerLiteral(t, ce.Consequence, 3)
+	testIntegerLiteral(t, ce.Alternative, 4)
+}
+
 func TestParsingIndexExpression(t *testing.T) {
 	input := `Array[2+2];`
 
@@ -632,6 +808,293 @@ func TestParsingIndexExpression(t *testing.T) {
 	testIdentifier(t, indexExp.Left, "Array")
 	testInfixExpression(t, indexExp.Right, 2, "+", 2)
 }
+
+func TestParsingRangeExpression(t *testing.T) {
+	tests := []struct {
+		input     string
+		inclusive bool
+	}{
+		{"1..5;", false},
+		{"1..=5;", true},
+	}
+
+	for _, tt := range tests {
+		program := testParsingInput(t, tt.input, 1)
+
+		stmnt, ok := program.Statements[0].(*ast.ExpressionStatement)
+		if !ok {
+			t.Fatalf("program.Statements[0] is not *ast.ExpressionStatement. got=%T", program.Statements[0])
+		}
+		rangeExp, ok := stmnt.Expression.(*ast.RangeExpression)
+		if !ok {
+			t.Fatalf("exp not *ast.RangeExpression. got=%T", stmnt.Expression)
+		}
+
+		testIntegerLiteral(t, rangeExp.Start, 1)
+		testIntegerLiteral(t, rangeExp.End, 5)
+		if rangeExp.Inclusive != tt.inclusive {
+			t.Errorf("rangeExp.Inclusive not %t. got=%t", tt.inclusive, rangeExp.Inclusive)
+		}
+	}
+}
+
+func TestForInStatement(t *testing.T) {
+	input := `for (x in [1, 2]) { print(x); }`
+
+	program := testParsingInput(t, input, 1)
+
+	stmnt, ok := program.Statements[0].(*ast.ForInStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not *ast.ForInStatement. got=%T", program.Statements[0])
+	}
+
+	if !testIdentifier(t, stmnt.Variable, "x") {
+		return
+	}
+
+	if stmnt.Iterable.String() != "[1, 2]" {
+		t.Errorf("stmnt.Iterable is not %q. got=%q", "[1, 2]", stmnt.Iterable.String())
+	}
+
+	if len(stmnt.Body.Statements) != 1 {
+		t.Fatalf("body is not 1 statement. got=%d", len(stmnt.Body.Statements))
+	}
+}
+
+func TestForStatement(t *testing.T) {
+	input := `for (i = 0; i < 10; i + 1) { print(i); }`
+
+	program := testParsingInput(t, input, 1)
+
+	stmnt, ok := program.Statements[0].(*ast.ForStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not *ast.ForStatement. got=%T", program.Statements[0])
+	}
+
+	testIdentifier(t, stmnt.Variable, "i")
+	testIntegerLiteral(t, stmnt.Init, 0)
+	testInfixExpression(t, stmnt.Condition, "i", "<", 10)
+	testInfixExpression(t, stmnt.Update, "i", "+", 1)
+
+	if len(stmnt.Body.Statements) != 1 {
+		t.Fatalf("body is not 1 statement. got=%d", len(stmnt.Body.Statements))
+	}
+	if program.String() != "for(i = 0; (i < 10); (i + 1)) print(i)" {
+		t.Errorf("wrong program string. got=%q", program.String())
+	}
+}
+
+func TestWhileStatement(t *testing.T) {
+	input := `while (x < 3) { print(x); }`
+
+	program := testParsingInput(t, input, 1)
+
+	stmnt, ok := program.Statements[0].(*ast.WhileStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not *ast.WhileStatement. got=%T", program.Statements[0])
+	}
+
+	if !testInfixExpression(t, stmnt.Condition, "x", "<", 3) {
+		return
+	}
+
+	if len(stmnt.Body.Statements) != 1 {
+		t.Fatalf("body is not 1 statement. got=%d", len(stmnt.Body.Statements))
+	}
+}
+
+func TestDoExpression(t *testing.T) {
+	input := `const x = do { const t = compute(); t * 2 };`
+
+	program := testParsingInput(t, input, 1)
+
+	stmnt, ok := program.Statements[0].(*ast.ConstStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not *ast.ConstStatement. got=%T", program.Statements[0])
+	}
+	exp, ok := stmnt.Value.(*ast.DoExpression)
+	if !ok {
+		t.Fatalf("stmnt.Value not *ast.DoExpression. got=%T", stmnt.Value)
+	}
+
+	if len(exp.Body.Statements) != 2 {
+		t.Fatalf("body is not 2 statements. got=%d", len(exp.Body.Statements))
+	}
+	if _, ok := exp.Body.Statements[0].(*ast.ConstStatement); !ok {
+		t.Errorf("exp.Body.Statements[0] is not *ast.ConstStatement. got=%T", exp.Body.Statements[0])
+	}
+	last, ok := exp.Body.Statements[1].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("exp.Body.Statements[1] is not *ast.ExpressionStatement. got=%T", exp.Body.Statements[1])
+	}
+	testInfixExpression(t, last.Expression, "t", "*", 2)
+}
+
+func TestWithExpression(t *testing.T) {
+	input := `with (x = 5, y = x + 1) { x * y };`
+
+	program := testParsingInput(t, input, 1)
+
+	stmnt, ok := program.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not *ast.ExpressionStatement. got=%T", program.Statements[0])
+	}
+	exp, ok := stmnt.Expression.(*ast.WithExpression)
+	if !ok {
+		t.Fatalf("exp not *ast.WithExpression. got=%T", stmnt.Expression)
+	}
+
+	if len(exp.Names) != 2 || len(exp.Values) != 2 {
+		t.Fatalf("wrong number of bindings, expected 2. got=%d", len(exp.Names))
+	}
+
+	testIdentifier(t, exp.Names[0], "x")
+	testIntegerLiteral(t, exp.Values[0], 5)
+	testIdentifier(t, exp.Names[1], "y")
+	testInfixExpression(t, exp.Values[1], "x", "+", 1)
+
+	if exp.Body.String() != "(x * y)" {
+		t.Errorf("body is not %q. got=%q", "(x * y)", exp.Body.String())
+	}
+}
+
+func TestRecordStatement(t *testing.T) {
+	input := `record Point(x, y);
+p.x;`
+
+	program := testParsingInput(t, input, 2)
+
+	stmnt, ok := program.Statements[0].(*ast.RecordStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not *ast.RecordStatement. got=%T", program.Statements[0])
+	}
+
+	testIdentifier(t, stmnt.Name, "Point")
+	if len(stmnt.Fields) != 2 {
+		t.Fatalf("wrong number of fields, expected 2. got=%d", len(stmnt.Fields))
+	}
+	testIdentifier(t, stmnt.Fields[0], "x")
+	testIdentifier(t, stmnt.Fields[1], "y")
+
+	exprStmnt, ok := program.Statements[1].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("program.Statements[1] is not *ast.ExpressionStatement. got=%T", program.Statements[1])
+	}
+	exp, ok := exprStmnt.Expression.(*ast.PropertyExpression)
+	if !ok {
+		t.Fatalf("exp not *ast.PropertyExpression. got=%T", exprStmnt.Expression)
+	}
+
+	testIdentifier(t, exp.Receiver, "p")
+	testIdentifier(t, exp.Property, "x")
+}
+
+func TestTypeSwitchExpression(t *testing.T) {
+	input := `typeswitch (x) { case Int: x + 1; case String: len(x); default: 0 };`
+
+	program := testParsingInput(t, input, 1)
+
+	stmnt, ok := program.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not *ast.ExpressionStatement. got=%T", program.Statements[0])
+	}
+	exp, ok := stmnt.Expression.(*ast.TypeSwitchExpression)
+	if !ok {
+		t.Fatalf("exp not *ast.TypeSwitchExpression. got=%T", stmnt.Expression)
+	}
+
+	testIdentifier(t, exp.Subject, "x")
+	if len(exp.Cases) != 2 {
+		t.Fatalf("wrong number of cases, expected 2. got=%d", len(exp.Cases))
+	}
+
+	testIdentifier(t, exp.Cases[0].Type, "Int")
+	testInfixExpression(t, exp.Cases[0].Body, "x", "+", 1)
+	testIdentifier(t, exp.Cases[1].Type, "String")
+	testIntegerLiteral(t, exp.Default, 0)
+
+	expected := "typeswitch (x) { case Int: (x + 1); case String: len(x); default: 0 }"
+	if exp.String() != expected {
+		t.Errorf("exp.String() wrong. expected=%q, got=%q", expected, exp.String())
+	}
+}
+
+func TestMatchExpression(t *testing.T) {
+	input := `match x { Point(a, 0) => a; [h, ...t] => h; "s" => 1; _ => 0 };`
+
+	program := testParsingInput(t, input, 1)
+
+	stmnt, ok := program.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not *ast.ExpressionStatement. got=%T", program.Statements[0])
+	}
+	exp, ok := stmnt.Expression.(*ast.MatchExpression)
+	if !ok {
+		t.Fatalf("exp not *ast.MatchExpression. got=%T", stmnt.Expression)
+	}
+
+	testIdentifier(t, exp.Subject, "x")
+	if len(exp.Arms) != 4 {
+		t.Fatalf("wrong number of arms, expected 4. got=%d", len(exp.Arms))
+	}
+
+	record, ok := exp.Arms[0].Pattern.(*ast.RecordPattern)
+	if !ok {
+		t.Fatalf("exp.Arms[0].Pattern not *ast.RecordPattern. got=%T", exp.Arms[0].Pattern)
+	}
+	testIdentifier(t, record.Name, "Point")
+	if len(record.Fields) != 2 {
+		t.Fatalf("wrong number of record pattern fields, expected 2. got=%d", len(record.Fields))
+	}
+	testIdentifier(t, record.Fields[0], "a")
+	testIntegerLiteral(t, record.Fields[1], 0)
+
+	array, ok := exp.Arms[1].Pattern.(*ast.ArrayPattern)
+	if !ok {
+		t.Fatalf("exp.Arms[1].Pattern not *ast.ArrayPattern. got=%T", exp.Arms[1].Pattern)
+	}
+	if len(array.Elements) != 1 {
+		t.Fatalf("wrong number of array pattern elements, expected 1. got=%d", len(array.Elements))
+	}
+	testIdentifier(t, array.Elements[0], "h")
+	testIdentifier(t, array.Rest, "t")
+
+	testIdentifier(t, exp.Arms[3].Pattern, "_")
+	testIntegerLiteral(t, exp.Arms[3].Body, 0)
+
+	expected := `match x { Point(a, 0) => a; [h, ...t] => h; s => 1; _ => 0 }`
+	if exp.String() != expected {
+		t.Errorf("exp.String() wrong. expected=%q, got=%q", expected, exp.String())
+	}
+}
+
+func TestGeneratorFunctionLiteral(t *testing.T) {
+	input := `fun(n) { const f = fun() { return 1; }; yield n; };`
+
+	program := testParsingInput(t, input, 1)
+
+	stmnt := program.Statements[0].(*ast.ExpressionStatement)
+	fl, ok := stmnt.Expression.(*ast.FunctionLiteral)
+	if !ok {
+		t.Fatalf("stmnt.Expression is not *ast.FunctionLiteral. got=%T", stmnt.Expression)
+	}
+
+	if !fl.Generator {
+		t.Errorf("function containing yield statement is not a generator")
+	}
+
+	inner := fl.Body.Statements[0].(*ast.ConstStatement).Value.(*ast.FunctionLiteral)
+	if inner.Generator {
+		t.Errorf("nested function without yield statement is a generator")
+	}
+
+	yield, ok := fl.Body.Statements[1].(*ast.YieldStatement)
+	if !ok {
+		t.Fatalf("fl.Body.Statements[1] is not *ast.YieldStatement. got=%T", fl.Body.Statements[1])
+	}
+	testIdentifier(t, yield.Value, "n")
+}
+
 func TestParsingHashLiteralsStringKeys(t *testing.T) {
 	input := `{"one": 1, "two": 2, "three": 3};`
 	expected := map[string]int64{
@@ -688,6 +1151,34 @@ func TestParsingEmptyHashLiteral(t *testing.T) {
 	}
 }
 
+func TestParsingHashLiteralsWithComputedKeys(t *testing.T) {
+	input := `{(a + b): "x"};`
+
+	program := testParsingInput(t, input, 1)
+
+	stm, ok := program.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not *ast.ExpressionStatement. got=%T", program.Statements[0])
+	}
+	hash, ok := stm.Expression.(*ast.HashLiteral)
+	if !ok {
+		t.Fatalf("exp is not ast.HashLiteral. got=%T", stm.Expression)
+	}
+
+	if len(hash.Pairs) != 1 {
+		t.Fatalf("hash.Pairs has wrong length. got=%d", len(hash.Pairs))
+	}
+
+	for key, value := range hash.Pairs {
+		testInfixExpression(t, key, "a", "+", "b")
+
+		str, ok := value.(*ast.StringLiteral)
+		if !ok || str.Value != "x" {
+			t.Errorf("value is not ast.StringLiteral %q. got=%T (%+v)", "x", value, value)
+		}
+	}
+}
+
 func TestParsingHashLiteralsWithExpressions(t *testing.T) {
 	input := `{"one": 0 + 1, "two": 10 - 8, "three": 15 / 5};`
 	tests := map[string]func(ast.Expression){
@@ -744,9 +1235,25 @@ func TestParserErrors(t *testing.T) {
 		{input: `const foo = "a string"`, expectedErrorMsg: "expected semicolon at line: 1"},
 		{input: `foo`, expectedErrorMsg: "expected semicolon at line: 1"},
 		{input: `const print = "a string";`, expectedErrorMsg: `cannot override built-in function: "print" at line: 1`},
+		{input: `const len = 5;`, expectedErrorMsg: `cannot override built-in function: "len" at line: 1`},
+		{input: `const f = fun(len) { len; };`, expectedErrorMsg: `cannot override built-in function: "len" at line: 1`},
+		{input: `for (len in [1]) { len; }`, expectedErrorMsg: `cannot override built-in function: "len" at line: 1`},
 		{input: `const foo "string";`, expectedErrorMsg: `unexpected token: "STRING" (expected: "=") at line: 1`},
 		{input: `=`, expectedErrorMsg: `unexpected token: "=" at line: 1`},
+		{input: `const a = 1, 2;`, expectedErrorMsg: `unexpected token: "INT" (expected: "IDENT") at line: 1`},
 		{input: `const foo = "a string"; foo = 1234;`, expectedErrorMsg: `cannot reassign constant: "foo" at line: 1`},
+		{input: "const count = 0;\nconst inc = fun() {\n\tcount = count + 1;\n};", expectedErrorMsg: `cannot reassign constant: "count" at line: 3`},
+		{input: "const count = 0;\nconst f = fun() { fun() { count = 1; } };", expectedErrorMsg: `cannot reassign constant: "count" at line: 2`},
+		{input: "const i = 0;\nwhile (i < 3) {\n\ti = i + 1;\n}", expectedErrorMsg: `cannot reassign constant: "i" at line: 3`},
+		{input: `9223372036854775808;`, expectedErrorMsg: `could not parse: "9223372036854775808" as integer at line: 1`},
+		{input: `-9223372036854775808[0];`, expectedErrorMsg: `could not parse: "9223372036854775808" as integer at line: 1`},
+		{input: `++i;`, expectedErrorMsg: `unexpected token: "+" at line: 1`},
+		{input: "const a = 1;\nconst b = 2;\n\nconst c = max(a, b;\nc;", expectedErrorMsg: `unexpected token: ";" (expected: ")") at line: 4`},
+		{input: `typeswitch (x) { when Int: 1 };`, expectedErrorMsg: `unexpected token: "when" (expected: "case" or "default") at line: 1`},
+		{input: `typeswitch (x) { default: 0; case Int: 1 };`, expectedErrorMsg: "default has to be the last case of typeswitch at line: 1"},
+		{input: `typeswitch x { default: 0 };`, expectedErrorMsg: `unexpected token: "IDENT" (expected: "(") at line: 1`},
+		{input: `"a ${} b";`, expectedErrorMsg: "empty expression in string interpolation at line: 1"},
+		{input: `"a ${1 2} b";`, expectedErrorMsg: `unexpected token: "INT" (expected: "EOF") at line: 1`},
 	}
 
 	for _, tt := range tests {
@@ -760,3 +1267,50 @@ func TestParserErrors(t *testing.T) {
 		}
 	}
 }
+
+func TestParserErrorRecovery(t *testing.T) {
+	tests := []struct {
+		input             string
+		expectedErrorMsgs []string
+	}{
+		{
+			input: "print(1 2);\nconst b = );\nconst c = 3;",
+			expectedErrorMsgs: []string{
+				`unexpected token: "INT" (expected: ")") at line: 1`,
+				`unexpected token: ")" at line: 2`,
+			},
+		},
+		{
+			input: "const a = [1 2 3];\nconst b = 4\nconst c = 5;",
+			expectedErrorMsgs: []string{
+				`unexpected token: "INT" (expected: "]") at line: 1`,
+				"expected semicolon at line: 2",
+			},
+		},
+		{
+			input: "if (true) { 1 + ; }\nconst c = );",
+			expectedErrorMsgs: []string{
+				`unexpected token: ";" at line: 1`,
+				`unexpected token: ")" at line: 2`,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+
+		p.ParseProgram()
+
+		if len(p.errors) != len(tt.expectedErrorMsgs) {
+			t.Errorf("wrong number of errors, expected: %d, got: %d %q", len(tt.expectedErrorMsgs), len(p.errors), p.errors)
+			continue
+		}
+
+		for i, msg := range tt.expectedErrorMsgs {
+			if p.errors[i] != msg {
+				t.Errorf("wrong error message, expected: %s, got: %s", msg, p.errors[i])
+			}
+		}
+	}
+}
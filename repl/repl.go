@@ -6,41 +6,299 @@ import (
 	"io"
 	"os"
 	"os/user"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/radlinskii/interpreter/ast"
 	"github.com/radlinskii/interpreter/object"
 
 	"github.com/radlinskii/interpreter/evaluator"
 
 	"github.com/radlinskii/interpreter/lexer"
 	"github.com/radlinskii/interpreter/parser"
+	"github.com/radlinskii/interpreter/token"
 )
 
 // PROMPT defines how the REPL's prompt will look like.
 const PROMPT = "👉  "
 
+// CONTINUATION_PROMPT is shown while the entered input has unclosed brackets.
+const CONTINUATION_PROMPT = "..  "
+
+// UNDO is the command reverting the last evaluated line.
+const UNDO = ":undo"
+
+// STATS is the command printing statistics of the last evaluated line.
+const STATS = ":stats"
+
+// JSON is the command printing value of the expression following it as JSON, e.g. :json {"a": 1}
+const JSON = ":json"
+
+// REPLAY is the command evaluating again the last N successfully evaluated lines, e.g. :replay 2
+const REPLAY = ":replay"
+
+// TRY is the command evaluating the line in a throwaway scope, so constants it declares don't persist, e.g. :try const a = 1; a + 1
+const TRY = ":try"
+
+// AST is the command printing the parsed program instead of evaluating it, e.g. :ast 1 + 2 * 3
+const AST = ":ast"
+
+// maxHistory limits how many evaluations can be undone and how many lines can be replayed.
+const maxHistory = 32
+
+// session holds the REPL's state between entered lines.
+type session struct {
+	out      io.Writer
+	errOut   io.Writer
+	env      *object.Environment
+	history  []*evaluation
+	input    []string
+	stats    *stats
+	warnings *object.Warnings
+}
+
+// evaluation is an entry of the session's history.
+// It holds the environment from before the line was evaluated and the line itself if it was evaluated successfully.
+type evaluation struct {
+	env  *object.Environment
+	line string
+}
+
+// stats describes how the last entered input was processed.
+type stats struct {
+	tokens     int
+	lexing     time.Duration
+	parsing    time.Duration
+	evaluation time.Duration
+}
+
+func newSession(out, errOut io.Writer) *session {
+	s := &session{out: out, errOut: errOut, env: object.NewEnvironment(), warnings: &object.Warnings{}}
+	s.env.SetWarnings(s.warnings)
+
+	return s
+}
+
 // Start runs the REPL loop.
 func Start(in io.Reader, out io.Writer) {
-	scanner := bufio.NewScanner(in)
-	env := object.NewEnvironment()
+	newSession(out, os.Stderr).run(in)
+}
+
+// run reads the lines from in, programs reading input with `readInt` or `readFloat` read the lines following them.
+func (s *session) run(in io.Reader) {
+	reader := bufio.NewReader(in)
+	s.env.SetInput(reader)
 
 	for {
-		fmt.Printf(PROMPT)
-		scanned := scanner.Scan()
-		if !scanned {
+		if len(s.input) == 0 {
+			fmt.Fprint(s.out, PROMPT)
+		} else {
+			fmt.Fprint(s.out, CONTINUATION_PROMPT)
+		}
+
+		line, err := reader.ReadString('\n')
+		if err != nil && line == "" {
 			return
 		}
 
-		line := scanner.Text()
-		l := lexer.New(line)
-		p := parser.New(l)
-		program := p.ParseProgram()
+		s.input = append(s.input, strings.TrimRight(line, "\r\n"))
+		input := strings.Join(s.input, "\n")
+		if isBalanced(input) {
+			s.input = nil
+			s.execute(input)
+		}
+	}
+}
+
+// isBalanced checks if all the brackets in the input are closed, so it's ready to be evaluated.
+// Input with not terminated string or comment isn't balanced either.
+func isBalanced(input string) bool {
+	l := lexer.New(input)
+	depth := 0
 
-		if len(p.Errors()) == 0 {
-			evaluated := evaluator.EvalProgram(program, env)
-			fmt.Println(evaluated)
+	for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+		switch tok.Type {
+		case token.LPAREN, token.LBRACE, token.LBRACKET:
+			depth++
+		case token.RPAREN, token.RBRACE, token.RBRACKET:
+			depth--
+		case token.ILLEGAL:
+			return !strings.Contains(tok.Literal, "not terminated")
 		}
+	}
+
+	return depth <= 0
+}
 
+// execute runs a REPL command or evaluates the line as a program.
+func (s *session) execute(line string) {
+	switch line {
+	case UNDO:
+		s.undo()
+		return
+	case STATS:
+		s.printStats()
+		return
 	}
+
+	if line == REPLAY || strings.HasPrefix(line, REPLAY+" ") {
+		s.replay(strings.TrimSpace(strings.TrimPrefix(line, REPLAY)))
+		return
+	}
+
+	if strings.HasPrefix(line, AST+" ") {
+		s.printAST(strings.TrimPrefix(line, AST))
+		return
+	}
+
+	if strings.HasPrefix(line, TRY+" ") {
+		s.evaluate(strings.TrimPrefix(line, TRY), object.NewEnclosedEnvironment(s.env), false)
+		return
+	}
+
+	s.evaluate(line, s.env, true)
+}
+
+// evaluate runs the line in given environment, value of the line starting with :json is printed as JSON.
+// Only persistent evaluations can be undone and replayed.
+func (s *session) evaluate(line string, env *object.Environment, persistent bool) {
+	st := &stats{}
+	s.stats = st
+
+	source := line
+	json := strings.HasPrefix(line, JSON+" ")
+	if json {
+		source = strings.TrimPrefix(line, JSON)
+	}
+
+	start := time.Now()
+	tokens := lexer.Tokenize(source)
+	st.tokens = len(tokens) - 1
+	st.lexing = time.Since(start)
+
+	// the semicolon ending the expression is optional after :json, it's put on a new line to be placed after a trailing comment
+	if json && (len(tokens) < 2 || tokens[len(tokens)-2].Type != token.SEMICOLON) {
+		source += "\n;"
+	}
+
+	start = time.Now()
+	l := lexer.New(source)
+	p := parser.New(l)
+	program := p.ParseProgram()
+	st.parsing = time.Since(start)
+
+	if json && len(p.Errors()) == 0 && !jsonStringifyCall(program) {
+		fmt.Fprintf(s.out, "usage: %s EXPRESSION\n", JSON)
+		return
+	}
+
+	if len(p.Errors()) == 0 {
+		if persistent {
+			s.pushHistory()
+		}
+
+		start = time.Now()
+		evaluated := evaluator.Eval(program, env)
+		st.evaluation = time.Since(start)
+
+		fmt.Fprintln(s.out, env.Output().Drain()+evaluated.Inspect())
+
+		if _, failed := evaluated.(*object.Error); persistent && !failed {
+			s.history[len(s.history)-1].line = line
+		}
+
+		for _, warning := range s.warnings.Drain() {
+			fmt.Fprintf(s.errOut, "warning: %s\n", warning)
+		}
+	}
+}
+
+// printAST prints the parsed program, parser errors are printed by the parser itself.
+func (s *session) printAST(input string) {
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+
+	if len(p.Errors()) == 0 {
+		fmt.Fprintln(s.out, program.String())
+	}
+}
+
+// jsonStringifyCall wraps the expression of the program in a call to the jsonStringify built-in,
+// returns false if the program isn't a single expression.
+func jsonStringifyCall(program *ast.Program) bool {
+	if len(program.Statements) != 1 {
+		return false
+	}
+
+	stmnt, ok := program.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		return false
+	}
+
+	tok := stmnt.Token
+	stmnt.Expression = &ast.CallExpression{
+		Token:     token.Token{Type: token.LPAREN, Literal: "(", LineNumber: tok.LineNumber, Column: tok.Column},
+		Function:  &ast.Identifier{Token: token.Token{Type: token.IDENT, Literal: "jsonStringify", LineNumber: tok.LineNumber, Column: tok.Column}, Value: "jsonStringify"},
+		Arguments: []ast.Expression{stmnt.Expression},
+	}
+
+	return true
+}
+
+// printStats prints to the error output statistics of the last evaluated line.
+// Parsing time includes lexing done by the parser.
+func (s *session) printStats() {
+	if s.stats == nil {
+		fmt.Fprintln(s.errOut, "no statistics yet")
+		return
+	}
+
+	fmt.Fprintf(s.errOut, "tokens: %d\n", s.stats.tokens)
+	fmt.Fprintf(s.errOut, "lexing: %s\n", s.stats.lexing)
+	fmt.Fprintf(s.errOut, "parsing: %s\n", s.stats.parsing)
+	fmt.Fprintf(s.errOut, "evaluation: %s\n", s.stats.evaluation)
+}
+
+// pushHistory saves the environment before evaluating a line.
+func (s *session) pushHistory() {
+	s.history = append(s.history, &evaluation{env: s.env.Snapshot()})
+	if len(s.history) > maxHistory {
+		s.history = s.history[1:]
+	}
+}
+
+// replay evaluates again the last count entered lines against the current environment.
+func (s *session) replay(count string) {
+	n, err := strconv.Atoi(count)
+	if err != nil || n <= 0 {
+		fmt.Fprintf(s.out, "usage: %s N, where N is a positive number of lines\n", REPLAY)
+		return
+	}
+
+	// replayed lines get saved in the history again, so they have to be collected first
+	var lines []string
+	for i := len(s.history) - 1; i >= 0 && len(lines) < n; i-- {
+		if s.history[i].line != "" {
+			lines = append([]string{s.history[i].line}, lines...)
+		}
+	}
+
+	for _, line := range lines {
+		s.execute(line)
+	}
+}
+
+// undo restores the environment from before the last evaluated line.
+func (s *session) undo() {
+	if len(s.history) == 0 {
+		fmt.Fprintln(s.out, "nothing to undo")
+		return
+	}
+
+	last := len(s.history) - 1
+	s.env.Restore(s.history[last].env)
+	s.history = s.history[:last]
 }
 
 func main() {
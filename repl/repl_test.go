@@ -0,0 +1,221 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func testSession(t *testing.T, input string) string {
+	output, _ := testSessionWithErrors(t, input)
+
+	return output
+}
+
+func testSessionWithErrors(t *testing.T, input string) (string, string) {
+	var out, errOut bytes.Buffer
+	newSession(&out, &errOut).run(strings.NewReader(input))
+
+	return out.String(), errOut.String()
+}
+
+func TestUndo(t *testing.T) {
+	output := testSession(t, "const a = 5;\na;\n:undo\n:undo\na;\n")
+
+	if strings.Count(output, "5\n") != 2 {
+		t.Errorf("expected a to be defined before undo. got=%q", output)
+	}
+	if !strings.Contains(output, "ERROR: line 1: unknown identifier: a") {
+		t.Errorf("expected a to be undefined after undo. got=%q", output)
+	}
+}
+
+func TestUndoWithEmptyHistory(t *testing.T) {
+	output := testSession(t, ":undo\n")
+
+	if !strings.Contains(output, "nothing to undo") {
+		t.Errorf("expected message about empty history. got=%q", output)
+	}
+}
+
+func TestReplay(t *testing.T) {
+	output := testSession(t, "const a = 2;\n1 +;\na * 3;\na + 1;\n:replay 2\n")
+
+	if strings.Count(output, "6\n") != 2 || strings.Count(output, "3\n") != 2 {
+		t.Errorf("expected last 2 lines to be evaluated again. got=%q", output)
+	}
+	if strings.Contains(output, "redeclared constant") {
+		t.Errorf("expected only last 2 lines to be replayed. got=%q", output)
+	}
+}
+
+func TestReplaySkipsFailedLines(t *testing.T) {
+	output := testSession(t, "const a = 2;\na * 3;\nlen(1);\n:replay 2\n")
+
+	if strings.Count(output, "6\n") != 2 {
+		t.Errorf("expected last 2 successful lines to be evaluated again. got=%q", output)
+	}
+	if strings.Count(output, "argument to `len` not supported") != 1 {
+		t.Errorf("expected line failed at runtime not to be replayed. got=%q", output)
+	}
+	if !strings.Contains(output, "redeclared constant") {
+		t.Errorf("expected line before the failed one to be replayed. got=%q", output)
+	}
+}
+
+func TestReplaySkipsUndoneLines(t *testing.T) {
+	output := testSession(t, "const a = 1;\n:undo\nconst b = 2;\n:replay 2\na;\n")
+
+	if strings.Count(output, "redeclared constant") != 1 {
+		t.Errorf("expected only the line that wasn't undone to be replayed. got=%q", output)
+	}
+	if !strings.Contains(output, "unknown identifier: a") {
+		t.Errorf("expected undone line not to be replayed. got=%q", output)
+	}
+}
+
+func TestReplayInvalidCount(t *testing.T) {
+	for _, input := range []string{":replay\n", ":replay 0\n", ":replay x\n"} {
+		output := testSession(t, input)
+
+		if !strings.Contains(output, "usage: :replay N") {
+			t.Errorf("expected usage message for %q. got=%q", input, output)
+		}
+	}
+}
+
+func TestTry(t *testing.T) {
+	output := testSession(t, "const a = 2;\n:try const b = a * 3; b + 1;\nb;\n:undo\na;\n")
+
+	if !strings.Contains(output, "7\n") {
+		t.Errorf("expected :try to read existing constants. got=%q", output)
+	}
+	if !strings.Contains(output, "unknown identifier: b") {
+		t.Errorf("expected constant declared inside :try not to leak. got=%q", output)
+	}
+	if strings.Contains(output, "unknown identifier: a") {
+		t.Errorf("expected :try not to be undoable. got=%q", output)
+	}
+}
+
+func TestWarnings(t *testing.T) {
+	output, errOutput := testSessionWithErrors(t, "const x = 1;\nconst y = do { const x = 2; x + 1 };\ny;\n")
+
+	if !strings.Contains(output, "3\n") {
+		t.Errorf("expected shadowing declaration to be evaluated. got=%q", output)
+	}
+	if errOutput != "warning: line 1: constant \"x\" shadows constant of an outer scope\n" {
+		t.Errorf("expected warning about shadowing. got=%q", errOutput)
+	}
+}
+
+func TestASTCommand(t *testing.T) {
+	output := testSession(t, ":ast 1 + 2 * -a;\nconst b = 1;\n:ast b;\nb;\n")
+
+	if !strings.Contains(output, "(1 + (2 * (-a)))\n") {
+		t.Errorf("expected parsed expression to be printed. got=%q", output)
+	}
+	if strings.Contains(output, "unknown identifier: a") {
+		t.Errorf("expected :ast not to evaluate the expression. got=%q", output)
+	}
+	if strings.Count(output, "1\n") != 2 || !strings.Contains(output, "b\n") {
+		t.Errorf("expected other lines to be evaluated. got=%q", output)
+	}
+}
+
+func TestIsBalanced(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{"1 + 2;", true},
+		{"const f = fun(x) {", false},
+		{"const f = fun(x) {\n return x;\n};", true},
+		{"[1, 2,", false},
+		{"print((1 + 2)", false},
+		{`const s = "}";`, true},
+		{`const s = "{";`, true},
+		{`const s = "a`, false},
+		{"const a = 1; // {", true},
+		{"const a = 1; /* {", false},
+		{"const a = 1; /* { */", true},
+		{"1 + 2);", true},
+		{"$ {", true},
+	}
+
+	for _, tt := range tests {
+		if isBalanced(tt.input) != tt.expected {
+			t.Errorf("isBalanced(%q) expected to be %t", tt.input, tt.expected)
+		}
+	}
+}
+
+func TestMultilineInput(t *testing.T) {
+	output := testSession(t, "const add = fun(x, y) {\n  x + y\n};\nadd(2, 3);\n")
+
+	if strings.Count(output, CONTINUATION_PROMPT) != 2 {
+		t.Errorf("expected continuation prompt for 2 lines. got=%q", output)
+	}
+	if !strings.Contains(output, "5\n") {
+		t.Errorf("expected multiline function to be evaluated. got=%q", output)
+	}
+}
+
+func TestStats(t *testing.T) {
+	_, errOutput := testSessionWithErrors(t, ":stats\nconst a = 1 + 2;\n:stats\n")
+
+	lines := strings.Split(strings.TrimSpace(errOutput), "\n")
+	if len(lines) != 5 {
+		t.Fatalf("expected 5 lines of statistics. got=%q", errOutput)
+	}
+
+	if lines[0] != "no statistics yet" {
+		t.Errorf("expected message about missing statistics. got=%q", lines[0])
+	}
+	if lines[1] != "tokens: 7" {
+		t.Errorf("wrong number of tokens. got=%q", lines[1])
+	}
+	for i, prefix := range []string{"lexing: ", "parsing: ", "evaluation: "} {
+		if !strings.HasPrefix(lines[i+2], prefix) {
+			t.Errorf("expected line starting with %q. got=%q", prefix, lines[i+2])
+		}
+	}
+}
+
+func TestJSONCommand(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`:json {"b": [1, true], "a": "x"};`, `{"a": "x", "b": [1, true]}`},
+		{"const h = {1: \"one\"};\n:json h", `{"1": "one"}`},
+		{`:json [{"a": {"b": 1}}, "c\\d"]`, `[{"a": {"b": 1}}, "c\\d"]`},
+		{`:json "say \"hi\""`, `"say \"hi\""`},
+		{`:json fun(x) { x }`, "ERROR: value of type FUNCTION can't be converted to JSON"},
+		{`:json {1: "a", "1": "b"}`, `ERROR: keys of different types convert to the same JSON key: "1"`},
+		{`:json {1: "a", true: "b"}`, `{"1": "a", "true": "b"}`},
+		{`:json [1, 2] // pair`, `[1, 2]`},
+		{"const p = [1, 2];\n:json p;\n:replay 1", "[1, 2]\n👉  [1, 2]"},
+		{`:json const a = 1;`, "usage: :json EXPRESSION"},
+		{`:json 1; 2;`, "usage: :json EXPRESSION"},
+	}
+
+	for _, tt := range tests {
+		output := testSession(t, tt.input+"\n")
+
+		if !strings.Contains(output, tt.expected) {
+			t.Errorf("expected output to contain %q. got=%q", tt.expected, output)
+		}
+	}
+}
+
+func TestReadingInput(t *testing.T) {
+	output := testSession(t, "readInt() * 2;\n21\nreadFloat();\n")
+
+	if !strings.Contains(output, "42\n") {
+		t.Errorf("expected readInt to read the following line. got=%q", output)
+	}
+	if !strings.Contains(output, "null\n") {
+		t.Errorf("expected readFloat to return null at the end of the input. got=%q", output)
+	}
+}
@@ -8,6 +8,7 @@ type Token struct {
 	Type       Type
 	Literal    string
 	LineNumber int
+	Column     int // column of the token's first character
 }
 
 const (
@@ -20,8 +21,12 @@ const (
 
 	// INT - integer literal
 	INT = "INT"
+	// FLOAT - floating-point number literal
+	FLOAT = "FLOAT"
 	// STRING - string literal
 	STRING = "STRING"
+	// TEMPLATE - string literal with embedded expressions, e.g. "${name} is ${age}"
+	TEMPLATE = "TEMPLATE"
 	// BOOLEAN - boolean literal
 	BOOLEAN = "BOOLEAN"
 
@@ -37,6 +42,10 @@ const (
 	ASTERISK = "*"
 	// SLASH - division
 	SLASH = "/"
+	// MODULO - remainder of division
+	MODULO = "%"
+	// DIV - floor division, keyword "div"
+	DIV = "div"
 
 	// LT - lower than
 	LT = "<"
@@ -50,13 +59,29 @@ const (
 	EQ = "=="
 	// NEQ - not equal
 	NEQ = "!="
+	// AND - logical conjunction, "&&" or "and"
+	AND = "&&"
+	// OR - logical disjunction, "||" or "or"
+	OR = "||"
 
 	// COMMA - values delimeter
 	COMMA = ","
 	// SEMICOLON - separates expressions
 	SEMICOLON = ";"
+	// QUESTION - separates condition from values of conditional expression
+	QUESTION = "?"
 	// COLON - separates key value pair in hashes
 	COLON = ":"
+	// DOT - method call on a receiver
+	DOT = "."
+	// RANGE - exclusive range of integers
+	RANGE = ".."
+	// RANGEINCL - inclusive range of integers
+	RANGEINCL = "..="
+	// ELLIPSIS - rest of the array in array patterns
+	ELLIPSIS = "..."
+	// ARROW - separates pattern from the result in match expressions
+	ARROW = "=>"
 
 	// LPAREN - function calls, binding expressions
 	LPAREN = "("
@@ -81,16 +106,50 @@ const (
 	IF = "IF"
 	// ELSE keyword "else"
 	ELSE = "ELSE"
+	// PURE keyword "pure"
+	PURE = "PURE"
+	// FOR keyword "for"
+	FOR = "FOR"
+	// WHILE keyword "while"
+	WHILE = "WHILE"
+	// IN keyword "in"
+	IN = "IN"
+	// DO keyword "do"
+	DO = "DO"
+	// WITH keyword "with"
+	WITH = "WITH"
+	// RECORD keyword "record"
+	RECORD = "RECORD"
+	// MATCH keyword "match"
+	MATCH = "MATCH"
+	// TYPESWITCH keyword "typeswitch"
+	TYPESWITCH = "TYPESWITCH"
+	// YIELD keyword "yield"
+	YIELD = "YIELD"
 )
 
 var keywords = map[string]Type{
-	"fun":    FUNCTION,
-	"const":  CONST,
-	"return": RETURN,
-	"true":   BOOLEAN,
-	"false":  BOOLEAN,
-	"if":     IF,
-	"else":   ELSE,
+	"fun":        FUNCTION,
+	"const":      CONST,
+	"return":     RETURN,
+	"true":       BOOLEAN,
+	"false":      BOOLEAN,
+	"if":         IF,
+	"else":       ELSE,
+	"pure":       PURE,
+	"for":        FOR,
+	"while":      WHILE,
+	"in":         IN,
+	"do":         DO,
+	"with":       WITH,
+	"record":     RECORD,
+	"match":      MATCH,
+	"typeswitch": TYPESWITCH,
+	"yield":      YIELD,
+	"and":        AND,
+	"or":         OR,
+	"not":        BANG,
+	"div":        DIV,
 }
 
 // LookUpIdent checks if identifier exists in the map of keywords.
@@ -100,3 +159,70 @@ func LookUpIdent(ident string) Type {
 	}
 	return IDENT
 }
+
+// names of the token types, as they are declared
+var names = map[Type]string{
+	ILLEGAL:    "ILLEGAL",
+	EOF:        "EOF",
+	IDENT:      "IDENT",
+	INT:        "INT",
+	FLOAT:      "FLOAT",
+	STRING:     "STRING",
+	TEMPLATE:   "TEMPLATE",
+	BOOLEAN:    "BOOLEAN",
+	ASSIGN:     "ASSIGN",
+	PLUS:       "PLUS",
+	MINUS:      "MINUS",
+	BANG:       "BANG",
+	ASTERISK:   "ASTERISK",
+	SLASH:      "SLASH",
+	MODULO:     "MODULO",
+	DIV:        "DIV",
+	LT:         "LT",
+	GT:         "GT",
+	LTE:        "LTE",
+	GTE:        "GTE",
+	EQ:         "EQ",
+	NEQ:        "NEQ",
+	AND:        "AND",
+	OR:         "OR",
+	COMMA:      "COMMA",
+	SEMICOLON:  "SEMICOLON",
+	QUESTION:   "QUESTION",
+	COLON:      "COLON",
+	DOT:        "DOT",
+	RANGE:      "RANGE",
+	RANGEINCL:  "RANGEINCL",
+	ELLIPSIS:   "ELLIPSIS",
+	ARROW:      "ARROW",
+	LPAREN:     "LPAREN",
+	RPAREN:     "RPAREN",
+	LBRACE:     "LBRACE",
+	RBRACE:     "RBRACE",
+	LBRACKET:   "LBRACKET",
+	RBRACKET:   "RBRACKET",
+	FUNCTION:   "FUNCTION",
+	RETURN:     "RETURN",
+	CONST:      "CONST",
+	IF:         "IF",
+	ELSE:       "ELSE",
+	PURE:       "PURE",
+	FOR:        "FOR",
+	WHILE:      "WHILE",
+	IN:         "IN",
+	DO:         "DO",
+	WITH:       "WITH",
+	RECORD:     "RECORD",
+	MATCH:      "MATCH",
+	TYPESWITCH: "TYPESWITCH",
+	YIELD:      "YIELD",
+}
+
+// String returns human-readable name of the token type, e.g. "ASSIGN" for "=",
+// or "UNKNOWN(<type>)" for types not declared in this package.
+func String(t Type) string {
+	if name, ok := names[t]; ok {
+		return name
+	}
+	return "UNKNOWN(" + string(t) + ")"
+}
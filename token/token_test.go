@@ -0,0 +1,56 @@
+package token
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"strconv"
+	"testing"
+)
+
+func TestString(t *testing.T) {
+	tests := []struct {
+		input    Type
+		expected string
+	}{
+		{ASSIGN, "ASSIGN"},
+		{EQ, "EQ"},
+		{IDENT, "IDENT"},
+		{FUNCTION, "FUNCTION"},
+		{RANGEINCL, "RANGEINCL"},
+		{"@", "UNKNOWN(@)"},
+	}
+
+	for _, tt := range tests {
+		if got := String(tt.input); got != tt.expected {
+			t.Errorf("wrong name of %q. expected=%q, got=%q", tt.input, tt.expected, got)
+		}
+	}
+}
+
+// every constant declared in token.go has to have its name
+func TestStringCoversDeclaredTypes(t *testing.T) {
+	file, err := parser.ParseFile(token.NewFileSet(), "token.go", nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, decl := range file.Decls {
+		gen, ok := decl.(*ast.GenDecl)
+		if !ok || gen.Tok != token.CONST {
+			continue
+		}
+
+		for _, spec := range gen.Specs {
+			value := spec.(*ast.ValueSpec)
+			literal, err := strconv.Unquote(value.Values[0].(*ast.BasicLit).Value)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got := String(Type(literal)); got != value.Names[0].Name {
+				t.Errorf("wrong name of %q. expected=%q, got=%q", literal, value.Names[0].Name, got)
+			}
+		}
+	}
+}